@@ -0,0 +1,258 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// ConversionDataAnnotation keeps the v1beta1 spec fields which can't be represented in v1alpha1,
+// so that converting to v1alpha1 and back does not lose them. The status isn't kept in it, because
+// v1alpha1 has all status fields and annotations are limited to 256KiB.
+const ConversionDataAnnotation = "operator.kyma-project.io/conversion-data"
+
+type conversionData struct {
+	Spec v1beta1.ClusterIPSpec `json:"spec"`
+}
+
+// ConvertTo converts this ClusterIP to the Hub version (v1beta1).
+func (src *ClusterIP) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.ClusterIP)
+	dst.ObjectMeta = src.ObjectMeta
+	return convertTo(&dst.ObjectMeta, &src.Spec, &src.Status, &dst.Spec, &dst.Status)
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *ClusterIP) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.ClusterIP)
+	dst.ObjectMeta = src.ObjectMeta
+	return convertFrom(&dst.ObjectMeta, &src.Spec, &src.Status, &dst.Spec, &dst.Status)
+}
+
+// ConvertTo converts this GlobalClusterIP to the Hub version (v1beta1).
+func (src *GlobalClusterIP) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.GlobalClusterIP)
+	dst.ObjectMeta = src.ObjectMeta
+	return convertTo(&dst.ObjectMeta, &src.Spec, &src.Status, &dst.Spec, &dst.Status)
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *GlobalClusterIP) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.GlobalClusterIP)
+	dst.ObjectMeta = src.ObjectMeta
+	return convertFrom(&dst.ObjectMeta, &src.Spec, &src.Status, &dst.Spec, &dst.Status)
+}
+
+func convertTo(meta *metav1.ObjectMeta, src *ClusterIPSpec, srcStatus *ClusterIPStatus, dst *v1beta1.ClusterIPSpec, dstStatus *v1beta1.ClusterIPStatus) error {
+	// start from the spec stored by the last conversion from v1beta1, and overwrite what v1alpha1 knows about
+	var restored conversionData
+	if data, ok := meta.Annotations[ConversionDataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &restored); err != nil {
+			return err
+		}
+		meta.Annotations = copyAnnotations(meta.Annotations)
+		delete(meta.Annotations, ConversionDataAnnotation)
+		if len(meta.Annotations) == 0 {
+			meta.Annotations = nil
+		}
+	}
+	*dst = restored.Spec
+
+	dst.NodeSpreadLabel = src.NodeSpreadLabel
+	dst.Notifications = nil
+	if n := src.Notifications; n != nil {
+		dst.Notifications = &v1beta1.Notifications{}
+		for _, w := range n.Webhooks {
+			dst.Notifications.Webhooks = append(dst.Notifications.Webhooks, v1beta1.WebhookNotification(w))
+		}
+		if n.CloudEvents != nil {
+			ce := v1beta1.CloudEventsNotification(*n.CloudEvents)
+			dst.Notifications.CloudEvents = &ce
+		}
+	}
+	dst.DNSEndpoint = nil
+	if src.DNSEndpoint != nil {
+		d := v1beta1.DNSEndpoint(*src.DNSEndpoint)
+		dst.DNSEndpoint = &d
+	}
+
+	*dstStatus = v1beta1.ClusterIPStatus{
+		State:              srcStatus.State,
+		Info:               srcStatus.Info,
+		Conditions:         srcStatus.Conditions,
+		LastRefreshRequest: srcStatus.LastRefreshRequest,
+		LastRefreshTime:    srcStatus.LastRefreshTime,
+	}
+	for _, n := range srcStatus.NodeIPs {
+		dstStatus.NodeIPs = append(dstStatus.NodeIPs, convertNodeIPTo(n))
+	}
+	for _, n := range srcStatus.Notifications {
+		status := v1beta1.NotificationStatus{
+			Name:            n.Name,
+			Target:          n.Target,
+			Failures:        n.Failures,
+			LastAttemptTime: n.LastAttemptTime,
+			LastSuccessTime: n.LastSuccessTime,
+			LastError:       n.LastError,
+		}
+		for _, d := range n.NodeIPs {
+			nodeIP := convertNodeIPTo(d)
+			status.Delivered = append(status.Delivered, v1beta1.DeliveredNodeIP{NodeLabel: d.NodeLabel, IPs: nodeIP.IPs(), Error: d.Error})
+		}
+		dstStatus.Notifications = append(dstStatus.Notifications, status)
+	}
+	return nil
+}
+
+// convertNodeIPTo converts the node IP to v1beta1. If a v1alpha1 client changed the IP, the addresses are replaced by it,
+// and the evidence, generation and metadata are dropped, because they don't apply to the changed IP.
+func convertNodeIPTo(src NodeIP) v1beta1.NodeIP {
+	dst := v1beta1.NodeIP{
+		NodeLabel:          src.NodeLabel,
+		LastUpdateTime:     src.LastUpdateTime,
+		Error:              src.Error,
+		ObservedGeneration: src.ObservedGeneration,
+	}
+	for _, a := range src.Addresses {
+		dst.Addresses = append(dst.Addresses, v1beta1.Address{IP: a.IP, Family: v1beta1.IPFamily(a.Family)})
+	}
+	for _, e := range src.Evidence {
+		dst.Evidence = append(dst.Evidence, v1beta1.Evidence(e))
+	}
+	for _, h := range src.ProviderHealth {
+		dst.ProviderHealth = append(dst.ProviderHealth, v1beta1.ProviderHealth(h))
+	}
+	if src.Metadata != nil {
+		m := v1beta1.IPMetadata(*src.Metadata)
+		dst.Metadata = &m
+	}
+	if dst.PrimaryIP() != src.IP {
+		dst.Addresses = nil
+		if src.IP != "" {
+			dst.Addresses = []v1beta1.Address{v1beta1.NewAddress(src.IP)}
+		}
+		dst.Evidence = nil
+		dst.ObservedGeneration = 0
+		dst.Metadata = nil
+	}
+	return dst
+}
+
+func convertFrom(meta *metav1.ObjectMeta, src *v1beta1.ClusterIPSpec, srcStatus *v1beta1.ClusterIPStatus, dst *ClusterIPSpec, dstStatus *ClusterIPStatus) error {
+	// keep only what v1alpha1 can't represent
+	kept := *src.DeepCopy()
+	kept.NodeSpreadLabel = ""
+	kept.Notifications = nil
+	kept.DNSEndpoint = nil
+	meta.Annotations = copyAnnotations(meta.Annotations)
+	if !equality.Semantic.DeepEqual(kept, v1beta1.ClusterIPSpec{}) {
+		data, err := json.Marshal(conversionData{Spec: kept})
+		if err != nil {
+			return err
+		}
+		meta.Annotations[ConversionDataAnnotation] = string(data)
+	} else {
+		delete(meta.Annotations, ConversionDataAnnotation)
+	}
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+
+	*dst = ClusterIPSpec{NodeSpreadLabel: src.NodeSpreadLabel}
+	if n := src.Notifications; n != nil {
+		dst.Notifications = &Notifications{}
+		for _, w := range n.Webhooks {
+			dst.Notifications.Webhooks = append(dst.Notifications.Webhooks, WebhookNotification(w))
+		}
+		if n.CloudEvents != nil {
+			ce := CloudEventsNotification(*n.CloudEvents)
+			dst.Notifications.CloudEvents = &ce
+		}
+	}
+	if src.DNSEndpoint != nil {
+		d := DNSEndpoint(*src.DNSEndpoint)
+		dst.DNSEndpoint = &d
+	}
+
+	*dstStatus = ClusterIPStatus{
+		State:              srcStatus.State,
+		Info:               srcStatus.Info,
+		Conditions:         srcStatus.Conditions,
+		LastRefreshRequest: srcStatus.LastRefreshRequest,
+		LastRefreshTime:    srcStatus.LastRefreshTime,
+	}
+	for _, n := range srcStatus.NodeIPs {
+		dstStatus.NodeIPs = append(dstStatus.NodeIPs, convertNodeIPFrom(n))
+	}
+	for _, n := range srcStatus.Notifications {
+		status := NotificationStatus{
+			Name:            n.Name,
+			Target:          n.Target,
+			Failures:        n.Failures,
+			LastAttemptTime: n.LastAttemptTime,
+			LastSuccessTime: n.LastSuccessTime,
+			LastError:       n.LastError,
+		}
+		for _, d := range n.Delivered {
+			nodeIP := v1beta1.NodeIP{NodeLabel: d.NodeLabel, Error: d.Error}
+			for _, ip := range d.IPs {
+				nodeIP.Addresses = append(nodeIP.Addresses, v1beta1.NewAddress(ip))
+			}
+			status.NodeIPs = append(status.NodeIPs, convertNodeIPFrom(nodeIP))
+		}
+		dstStatus.Notifications = append(dstStatus.Notifications, status)
+	}
+	return nil
+}
+
+func convertNodeIPFrom(src v1beta1.NodeIP) NodeIP {
+	dst := NodeIP{
+		NodeLabel:          src.NodeLabel,
+		IP:                 src.PrimaryIP(),
+		LastUpdateTime:     src.LastUpdateTime,
+		Error:              src.Error,
+		ObservedGeneration: src.ObservedGeneration,
+	}
+	for _, a := range src.Addresses {
+		dst.Addresses = append(dst.Addresses, Address{IP: a.IP, Family: IPFamily(a.Family)})
+	}
+	for _, e := range src.Evidence {
+		dst.Evidence = append(dst.Evidence, Evidence(e))
+	}
+	for _, h := range src.ProviderHealth {
+		dst.ProviderHealth = append(dst.ProviderHealth, ProviderHealth(h))
+	}
+	if src.Metadata != nil {
+		m := IPMetadata(*src.Metadata)
+		dst.Metadata = &m
+	}
+	return dst
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	result := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// times are serialized with second precision, so the fixtures use whole seconds
+var now = metav1.NewTime(time.Now().Truncate(time.Second))
+
+func alphaSpec() ClusterIPSpec {
+	return ClusterIPSpec{
+		NodeSpreadLabel: "topology.kubernetes.io/zone",
+		Notifications: &Notifications{
+			Webhooks: []WebhookNotification{{
+				URL:       "https://example.com/hook",
+				SecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "secret"}, Key: "hmac"},
+				Headers:   map[string]string{"Authorization": "Bearer token"},
+			}},
+			CloudEvents: &CloudEventsNotification{Sink: "http://broker", Mode: "structured"},
+		},
+		DNSEndpoint: &DNSEndpoint{DNSName: "egress.example.com", TTL: 60},
+	}
+}
+
+func betaNodeIPs() []v1beta1.NodeIP {
+	return []v1beta1.NodeIP{
+		{
+			NodeLabel: "a",
+			Addresses: []v1beta1.Address{{IP: "2001:db8::1", Family: v1beta1.IPv6}, {IP: "1.2.3.4", Family: v1beta1.IPv4}},
+			Evidence: []v1beta1.Evidence{
+				{Provider: "ipinfo.io", IP: "1.2.3.4"},
+				{Provider: "ifconfig.me", Error: "timeout"},
+			},
+			LastUpdateTime:     now,
+			ObservedGeneration: 3,
+			ProviderHealth: []v1beta1.ProviderHealth{{
+				Provider: "ipinfo.io", Successes: 5, Failures: 1, LatencyMilliseconds: 120,
+				Requests: 2, WindowStart: &now,
+			}, {
+				Provider: "ifconfig.me", Failures: 3, ConsecutiveFailures: 3, CircuitOpenUntil: &now,
+			}},
+			Metadata: &v1beta1.IPMetadata{Country: "DE", City: "Frankfurt am Main", ASN: 16509, Org: "Amazon.com, Inc.", Source: "ipinfo.io"},
+		},
+		{NodeLabel: "b", Error: "only 0 of 2 required services returned valid IP", LastUpdateTime: now},
+	}
+}
+
+// TestNodeIPFixture makes sure the fixture sets every field of v1beta1.NodeIP but Error,
+// so that a new field fails the round trip tests until the conversion keeps it.
+func TestNodeIPFixture(t *testing.T) {
+	v := reflect.ValueOf(betaNodeIPs()[0])
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; name != "Error" && v.Field(i).IsZero() {
+			t.Errorf("betaNodeIPs doesn't set NodeIP.%s", name)
+		}
+	}
+}
+
+func TestClusterIPAlphaRoundTrip(t *testing.T) {
+	src := &ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Annotations: map[string]string{"foo": "bar"}},
+		Spec:       alphaSpec(),
+		Status: ClusterIPStatus{
+			State:   "Ready",
+			NodeIPs: []NodeIP{{NodeLabel: "a", IP: "1.2.3.4", LastUpdateTime: now}, {NodeLabel: "b", Error: "timeout"}},
+			Conditions: []metav1.Condition{{
+				Type: "DNSEndpointReady", Status: metav1.ConditionTrue, Reason: "Published", LastTransitionTime: now,
+			}},
+			Notifications: []NotificationStatus{{Target: "https://example.com/hook", NodeIPs: []NodeIP{{NodeLabel: "a", IP: "1.2.3.4"}}, Failures: 1}},
+		},
+	}
+	hub := &v1beta1.ClusterIP{}
+	if err := src.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if ips := hub.Status.NodeIPs[0].Addresses; len(ips) != 1 || ips[0] != (v1beta1.Address{IP: "1.2.3.4", Family: v1beta1.IPv4}) {
+		t.Errorf("unexpected addresses %+v", ips)
+	}
+	dst := &ClusterIP{}
+	if err := dst.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dst.Annotations[ConversionDataAnnotation]; ok {
+		t.Errorf("expected no %s annotation for a spec v1alpha1 can represent", ConversionDataAnnotation)
+	}
+	// the addresses are filled from the IP
+	expected := src.DeepCopy()
+	expected.Status.NodeIPs[0].Addresses = []Address{{IP: "1.2.3.4", Family: "IPv4"}}
+	expected.Status.Notifications[0].NodeIPs[0].Addresses = []Address{{IP: "1.2.3.4", Family: "IPv4"}}
+	if !equality.Semantic.DeepEqual(expected, dst) {
+		t.Errorf("round trip changed the object\nexpected: %+v\ngot:      %+v", expected, dst)
+	}
+}
+
+func TestClusterIPBetaRoundTrip(t *testing.T) {
+	src := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1beta1.ClusterIPSpec{
+			NodeSpreadLabel: "kubernetes.io/hostname",
+			DNSEndpoint:     &v1beta1.DNSEndpoint{DNSName: "egress.example.com", TTL: 300},
+			RefreshInterval: &metav1.Duration{Duration: time.Hour},
+			ExpectedCIDRs:   []string{"1.2.3.0/24"},
+			Providers:       []v1beta1.Provider{{Name: "echo", URL: "https://echo.example.com", Format: v1beta1.ResponseFormatEcho}},
+		},
+		Status: v1beta1.ClusterIPStatus{
+			State:              "Processing",
+			NodeIPs:            betaNodeIPs(),
+			LastRefreshRequest: "2023-01-01T00:00:00Z",
+			LastRefreshTime:    &now,
+			Notifications:      []v1beta1.NotificationStatus{{Name: "webhooks[0]", Target: "https://example.com/hook", Delivered: []v1beta1.DeliveredNodeIP{{NodeLabel: "a", IPs: []string{"2001:db8::1", "1.2.3.4"}}}, LastSuccessTime: &now}},
+		},
+	}
+	spoke := &ClusterIP{}
+	if err := spoke.ConvertFrom(src.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if ip := spoke.Status.NodeIPs[0].IP; ip != "1.2.3.4" {
+		t.Errorf("expected IPv4 address to be preferred, got %s", ip)
+	}
+	// the annotation holds only the spec fields v1alpha1 doesn't have
+	var data map[string]map[string]any
+	if err := json.Unmarshal([]byte(spoke.Annotations[ConversionDataAnnotation]), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || len(data["spec"]) != 3 || data["spec"]["nodeSpreadLabel"] != nil {
+		t.Errorf("unexpected conversion data %v", data)
+	}
+	dst := &v1beta1.ClusterIP{}
+	if err := spoke.ConvertTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(src, dst) {
+		t.Errorf("round trip changed the object\nexpected: %+v\ngot:      %+v", src, dst)
+	}
+}
+
+func TestClusterIPBetaRoundTripWithAlphaChange(t *testing.T) {
+	src := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Status:     v1beta1.ClusterIPStatus{NodeIPs: betaNodeIPs()},
+	}
+	spoke := &ClusterIP{}
+	if err := spoke.ConvertFrom(src); err != nil {
+		t.Fatal(err)
+	}
+	spoke.Spec.NodeSpreadLabel = "kubernetes.io/os"
+	spoke.Status.NodeIPs[0].IP = "5.6.7.8"
+	dst := &v1beta1.ClusterIP{}
+	if err := spoke.ConvertTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Spec.NodeSpreadLabel != "kubernetes.io/os" {
+		t.Errorf("expected changed spec, got %+v", dst.Spec)
+	}
+	// addresses, evidence and metadata of the v1beta1 entry don't apply to the changed IP anymore
+	expected := v1beta1.NodeIP{
+		NodeLabel:      "a",
+		Addresses:      []v1beta1.Address{{IP: "5.6.7.8", Family: v1beta1.IPv4}},
+		LastUpdateTime: now,
+		ProviderHealth: betaNodeIPs()[0].ProviderHealth,
+	}
+	if !equality.Semantic.DeepEqual(dst.Status.NodeIPs[0], expected) {
+		t.Errorf("expected %+v, got %+v", expected, dst.Status.NodeIPs[0])
+	}
+	if len(dst.Annotations) != 0 {
+		t.Errorf("expected no annotations, got %v", dst.Annotations)
+	}
+}
+
+func TestGlobalClusterIPBetaRoundTrip(t *testing.T) {
+	src := &v1beta1.GlobalClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "zones", Annotations: map[string]string{"foo": "bar"}},
+		Spec:       v1beta1.ClusterIPSpec{NodeSpreadLabel: "topology.kubernetes.io/zone"},
+		Status:     v1beta1.ClusterIPStatus{State: "Ready", NodeIPs: betaNodeIPs()},
+	}
+	spoke := &GlobalClusterIP{}
+	if err := spoke.ConvertFrom(src.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	dst := &v1beta1.GlobalClusterIP{}
+	if err := spoke.ConvertTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(src, dst) {
+		t.Errorf("round trip changed the object\nexpected: %+v\ngot:      %+v", src, dst)
+	}
+}
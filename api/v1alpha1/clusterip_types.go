@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -27,11 +28,98 @@ type ClusterIPSpec struct {
 
 	//+kubebuilder:default=topology.kubernetes.io/zone
 	NodeSpreadLabel string `json:"nodeSpreadLabel,omitempty"`
+
+	// Notifications configures receivers informed whenever the node IPs change.
+	Notifications *Notifications `json:"notifications,omitempty"`
+
+	// DNSEndpoint publishes the node IPs as external-dns DNSEndpoint with A and AAAA records.
+	DNSEndpoint *DNSEndpoint `json:"dnsEndpoint,omitempty"`
+}
+
+type DNSEndpoint struct {
+	DNSName string `json:"dnsName"`
+	//+kubebuilder:default=300
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type Notifications struct {
+	Webhooks    []WebhookNotification    `json:"webhooks,omitempty"`
+	CloudEvents *CloudEventsNotification `json:"cloudEvents,omitempty"`
+}
+
+// WebhookNotification describes an HTTP endpoint receiving a JSON payload with the old and new IPs.
+type WebhookNotification struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// SecretRef selects a key of a Secret in the ClusterIP namespace used to sign the payload with HMAC-SHA256.
+	// The signature is sent in the X-Cluster-IP-Signature header.
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+	Headers   map[string]string         `json:"headers,omitempty"`
+}
+
+// CloudEventsNotification describes a sink receiving CloudEvents about discovered, changed, removed and failed node IPs.
+type CloudEventsNotification struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	Sink string `json:"sink"`
+	// Mode selects the HTTP content mode of the CloudEvents protocol binding.
+	//+kubebuilder:validation:Enum=binary;structured
+	//+kubebuilder:default=binary
+	Mode    string            `json:"mode,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 type NodeIP struct {
 	NodeLabel      string      `json:"nodeLabel"`
 	IP             string      `json:"ip"`
 	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Error is the reason of the last failed attempt to determine the IP.
+	Error string `json:"error,omitempty"`
+
+	// The following fields mirror v1beta1, so that the status converts without an annotation.
+	// They are ignored if IP isn't the primary address of Addresses anymore.
+
+	Addresses          []Address        `json:"addresses,omitempty"`
+	Evidence           []Evidence       `json:"evidence,omitempty"`
+	ObservedGeneration int64            `json:"observedGeneration,omitempty"`
+	ProviderHealth     []ProviderHealth `json:"providerHealth,omitempty"`
+	Metadata           *IPMetadata      `json:"metadata,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=IPv4;IPv6
+type IPFamily string
+
+type Address struct {
+	IP     string   `json:"ip"`
+	Family IPFamily `json:"family,omitempty"`
+}
+
+// Evidence is the answer of a single IP provider consulted by the worker.
+type Evidence struct {
+	Provider   string `json:"provider"`
+	IP         string `json:"ip,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	StatusCode int32  `json:"statusCode,omitempty"`
+}
+
+// ProviderHealth is the statistics of an IP provider.
+type ProviderHealth struct {
+	Provider            string       `json:"provider"`
+	Successes           int32        `json:"successes,omitempty"`
+	Failures            int32        `json:"failures,omitempty"`
+	ConsecutiveFailures int32        `json:"consecutiveFailures,omitempty"`
+	LatencyMilliseconds int64        `json:"latencyMilliseconds,omitempty"`
+	CircuitOpenUntil    *metav1.Time `json:"circuitOpenUntil,omitempty"`
+	Requests            int32        `json:"requests,omitempty"`
+	WindowStart         *metav1.Time `json:"windowStart,omitempty"`
+}
+
+// IPMetadata describes where an egress IP is located and which network it belongs to.
+type IPMetadata struct {
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     int64  `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"`
+	Source  string `json:"source,omitempty"`
 }
 
 // ClusterIPStatus defines the observed state of ClusterIP
@@ -43,6 +131,29 @@ type ClusterIPStatus struct {
 	State   string   `json:"state"`
 	Info    string   `json:"info,omitempty"`
 	NodeIPs []NodeIP `json:"nodeIPs,omitempty"`
+
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	Notifications []NotificationStatus `json:"notifications,omitempty"`
+
+	LastRefreshRequest string       `json:"lastRefreshRequest,omitempty"`
+	LastRefreshTime    *metav1.Time `json:"lastRefreshTime,omitempty"`
+}
+
+// NotificationStatus records the delivery state of a single notification target.
+type NotificationStatus struct {
+	// Name identifies the target in the spec, webhooks[<index>] or cloudEvents.
+	Name   string `json:"name,omitempty"`
+	Target string `json:"target"`
+	// NodeIPs is the last state successfully delivered to the target.
+	NodeIPs []NodeIP `json:"nodeIPs,omitempty"`
+	// Failures counts consecutive failed deliveries.
+	Failures        int          `json:"failures,omitempty"`
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	LastError       string       `json:"lastError,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,401 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RefreshRequestedAnnotation requests an immediate re-discovery of the node IPs when its value changes.
+// kubectl-clusterip sets it to the current time in RFC 3339 format.
+const RefreshRequestedAnnotation = "cluster-ip.operator.kyma-project.io/refresh-requested-at"
+
+// ClusterIPSpec defines the desired state of ClusterIP
+type ClusterIPSpec struct {
+	//+kubebuilder:default=topology.kubernetes.io/zone
+	NodeSpreadLabel string `json:"nodeSpreadLabel,omitempty"`
+
+	// Notifications configures receivers informed whenever the node IPs change.
+	Notifications *Notifications `json:"notifications,omitempty"`
+
+	// DNSEndpoint publishes the node IPs as external-dns DNSEndpoint with A and AAAA records.
+	DNSEndpoint *DNSEndpoint `json:"dnsEndpoint,omitempty"`
+
+	// RefreshInterval is how long the determined node IPs are used before workers determine them again.
+	//+kubebuilder:default="24h"
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// WorkerImage overrides the image of the worker pods, which by default is the image of the operator.
+	// Its repository must be allowed with the --allowed-worker-images flag of the operator.
+	WorkerImage string `json:"workerImage,omitempty"`
+
+	// WorkerStartJitter spreads the start of the workers over the given duration, so that many workers
+	// don't call the providers at the same time.
+	//+kubebuilder:default="10s"
+	WorkerStartJitter *metav1.Duration `json:"workerStartJitter,omitempty"`
+
+	// ExpectedCIDRs are the networks the egress IPs must be in, like the reserved IPs of the NAT gateways.
+	// Node IPs outside of them set the Compliant condition to False.
+	ExpectedCIDRs []string `json:"expectedCIDRs,omitempty"`
+
+	// AllowedAddressClasses are the non-public addresses accepted as egress IP, like Private to discover
+	// the egress IP of a cluster within a private network. By default only public addresses are accepted,
+	// because a provider answering with another one is misconfigured or behind a proxy.
+	AllowedAddressClasses []AddressClass `json:"allowedAddressClasses,omitempty"`
+
+	// Providers replace the default services the workers ask for the egress IP.
+	Providers []Provider `json:"providers,omitempty"`
+
+	// HTTP configures how the workers call all providers. The settings of a provider take precedence.
+	HTTP *HTTPConfig `json:"http,omitempty"`
+}
+
+// Provider is an HTTP service answering with the IP the request comes from.
+type Provider struct {
+	Name string `json:"name"`
+	//+kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// Format selects how the IP is extracted from the response: json at JSONPath,
+	// text with the IP as the whole body, regex matched by Regex, or echo for the echo-server subcommand of the operator.
+	//+kubebuilder:validation:Enum=json;text;regex;echo
+	//+kubebuilder:default=json
+	Format ResponseFormat `json:"format,omitempty"`
+	// JSONPath is the gjson path of the IP in the JSON response, like ip or data.client.ip.
+	//+kubebuilder:default=ip
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Regex matches the IP in the response, or its first capture group matches it if it has one.
+	Regex string `json:"regex,omitempty"`
+	// PublicKey is the Ed25519 public key, PEM encoded or as base64 of the 32 key bytes, which must have signed
+	// the answers of a provider with the echo format. Unsigned answers are rejected then.
+	PublicKey string `json:"publicKey,omitempty"`
+	// Metadata are the paths of the location and network of the IP in the JSON response, if the provider returns them.
+	Metadata *MetadataPaths `json:"metadata,omitempty"`
+	// Budget limits the requests to the provider by all workers of the ClusterIP.
+	Budget *ProviderBudget `json:"budget,omitempty"`
+
+	HTTPConfig `json:",inline"`
+}
+
+// MetadataPaths are gjson paths in the JSON response of a provider.
+type MetadataPaths struct {
+	// CountryPath is the path of the ISO 3166-1 alpha-2 country code.
+	CountryPath string `json:"countryPath,omitempty"`
+	CityPath    string `json:"cityPath,omitempty"`
+	// ASNPath is the path of the autonomous system number, a number or a string like AS15169.
+	ASNPath string `json:"asnPath,omitempty"`
+	// OrgPath is the path of the organization. A leading ASN, like in AS15169 Google LLC, is used if there is no ASNPath.
+	OrgPath string `json:"orgPath,omitempty"`
+}
+
+// ProviderBudget allows at most Requests requests within each Period, aligned to the clock, like every full hour.
+type ProviderBudget struct {
+	//+kubebuilder:validation:Minimum=1
+	Requests int32           `json:"requests"`
+	Period   metav1.Duration `json:"period"`
+}
+
+// Ed25519PublicKey parses the public key of the provider. It returns nil if the provider has none.
+func (p *Provider) Ed25519PublicKey() (ed25519.PublicKey, error) {
+	value := strings.TrimSpace(p.PublicKey)
+	if value == "" {
+		return nil, nil
+	}
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ed25519Key, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("expected an Ed25519 key, got %T", key)
+		}
+		return ed25519Key, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("expected a PEM block or base64: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("expected %d key bytes, got %d", ed25519.PublicKeySize, len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// AddressClass is a range of special-purpose addresses.
+// +kubebuilder:validation:Enum=Private;CGNAT;Loopback;LinkLocal;Documentation;Unspecified;Multicast;Reserved
+type AddressClass string
+
+type ResponseFormat string
+
+const (
+	ResponseFormatJSON  ResponseFormat = "json"
+	ResponseFormatText  ResponseFormat = "text"
+	ResponseFormatRegex ResponseFormat = "regex"
+	ResponseFormatEcho  ResponseFormat = "echo"
+)
+
+// HTTPConfig configures the HTTP client calling the providers.
+// Secrets and config maps are read from the ClusterIP namespace, or the operator namespace for GlobalClusterIP.
+type HTTPConfig struct {
+	// ProxyURL is the HTTP proxy the providers are called through, like http://proxy.example.com:3128.
+	// Without it the HTTPS_PROXY environment variable of the worker is used.
+	ProxyURL string `json:"proxyURL,omitempty"`
+	// ProxyURLSecretRef selects a Secret key holding the proxy URL, for proxies requiring credentials in the URL.
+	ProxyURLSecretRef *corev1.SecretKeySelector `json:"proxyURLSecretRef,omitempty"`
+	// CABundle adds PEM encoded certificate authorities trusted next to the system ones, like the one of a TLS intercepting proxy.
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+	// ClientCertificateSecretRef selects a kubernetes.io/tls Secret with the client certificate and key.
+	ClientCertificateSecretRef *corev1.LocalObjectReference `json:"clientCertificateSecretRef,omitempty"`
+	Headers                    map[string]string            `json:"headers,omitempty"`
+	// HeadersFrom sets headers to values of Secret keys, like API tokens.
+	HeadersFrom []HeaderSource `json:"headersFrom,omitempty"`
+}
+
+// CABundleSource selects either a Secret or a ConfigMap key.
+type CABundleSource struct {
+	SecretKeyRef    *corev1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+type HeaderSource struct {
+	Name         string                   `json:"name"`
+	SecretKeyRef corev1.SecretKeySelector `json:"secretKeyRef"`
+}
+
+type DNSEndpoint struct {
+	DNSName string `json:"dnsName"`
+	//+kubebuilder:default=300
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+type Notifications struct {
+	Webhooks    []WebhookNotification    `json:"webhooks,omitempty"`
+	CloudEvents *CloudEventsNotification `json:"cloudEvents,omitempty"`
+}
+
+// WebhookNotification describes an HTTP endpoint receiving a JSON payload with the old and new IPs.
+type WebhookNotification struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// SecretRef selects a key of a Secret in the ClusterIP namespace used to sign the payload with HMAC-SHA256.
+	// The signature is sent in the X-Cluster-IP-Signature header.
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+	Headers   map[string]string         `json:"headers,omitempty"`
+}
+
+// CloudEventsNotification describes a sink receiving CloudEvents about discovered, changed, removed and failed node IPs.
+type CloudEventsNotification struct {
+	//+kubebuilder:validation:Pattern=`^https?://`
+	Sink string `json:"sink"`
+	// Mode selects the HTTP content mode of the CloudEvents protocol binding.
+	//+kubebuilder:validation:Enum=binary;structured
+	//+kubebuilder:default=binary
+	Mode    string            `json:"mode,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=IPv4;IPv6
+type IPFamily string
+
+const (
+	IPv4 IPFamily = "IPv4"
+	IPv6 IPFamily = "IPv6"
+)
+
+type Address struct {
+	IP     string   `json:"ip"`
+	Family IPFamily `json:"family,omitempty"`
+}
+
+// Evidence is the answer of a single IP provider consulted by the worker.
+type Evidence struct {
+	Provider string `json:"provider"`
+	IP       string `json:"ip,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Reason classifies why the answer was rejected, like HTTPStatus, ContentType or DecodeFailed.
+	Reason string `json:"reason,omitempty"`
+	// StatusCode is the HTTP status code of the provider response.
+	StatusCode int32 `json:"statusCode,omitempty"`
+}
+
+// NodeIP holds the egress addresses determined for a single value of the node spread label.
+type NodeIP struct {
+	NodeLabel string    `json:"nodeLabel"`
+	Addresses []Address `json:"addresses,omitempty"`
+	// Evidence lists the provider answers the addresses were determined from.
+	Evidence       []Evidence  `json:"evidence,omitempty"`
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Error is the reason of the last failed attempt to determine the addresses.
+	Error string `json:"error,omitempty"`
+	// ObservedGeneration is the generation of the ClusterIP the addresses were determined for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ProviderHealth is the health of the providers asked by the workers of the node label.
+	ProviderHealth []ProviderHealth `json:"providerHealth,omitempty"`
+	// Metadata is the location and network of the addresses, if the providers returned them.
+	Metadata *IPMetadata `json:"metadata,omitempty"`
+}
+
+// IPMetadata describes where an egress IP is located and which network it belongs to,
+// so that it can be verified that a zone egresses from the expected region and cloud provider.
+type IPMetadata struct {
+	// Country is the ISO 3166-1 alpha-2 code, like DE.
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	// ASN is the number of the autonomous system announcing the IP.
+	ASN int64 `json:"asn,omitempty"`
+	// Org is the organization owning the autonomous system or the IP, like a cloud provider.
+	Org string `json:"org,omitempty"`
+	// Source is the provider the metadata comes from, or the types of the local GeoIP databases, like GeoLite2-City,GeoLite2-ASN.
+	Source string `json:"source,omitempty"`
+}
+
+// ProviderHealth is the statistics of an IP provider. Providers failing repeatedly are skipped
+// until CircuitOpenUntil, as long as enough other providers are left for the quorum.
+type ProviderHealth struct {
+	Provider            string `json:"provider"`
+	Successes           int32  `json:"successes,omitempty"`
+	Failures            int32  `json:"failures,omitempty"`
+	ConsecutiveFailures int32  `json:"consecutiveFailures,omitempty"`
+	// LatencyMilliseconds is the moving average of the response time.
+	LatencyMilliseconds int64        `json:"latencyMilliseconds,omitempty"`
+	CircuitOpenUntil    *metav1.Time `json:"circuitOpenUntil,omitempty"`
+	// Requests is the number of requests to a provider with a budget in the budget period starting at WindowStart.
+	Requests    int32        `json:"requests,omitempty"`
+	WindowStart *metav1.Time `json:"windowStart,omitempty"`
+}
+
+// IPs returns the addresses without the family.
+func (n *NodeIP) IPs() []string {
+	result := make([]string, 0, len(n.Addresses))
+	for _, a := range n.Addresses {
+		result = append(result, a.IP)
+	}
+	return result
+}
+
+// PrimaryIP returns the first IPv4 address, or the first address if there is no IPv4 one.
+func (n *NodeIP) PrimaryIP() string {
+	for _, a := range n.Addresses {
+		if a.Family == IPv4 {
+			return a.IP
+		}
+	}
+	if len(n.Addresses) > 0 {
+		return n.Addresses[0].IP
+	}
+	return ""
+}
+
+// NewAddress returns the address with the family detected from the IP.
+func NewAddress(ip string) Address {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Address{IP: ip}
+	}
+	if addr.Unmap().Is4() {
+		return Address{IP: ip, Family: IPv4}
+	}
+	return Address{IP: ip, Family: IPv6}
+}
+
+// ClusterIPStatus defines the observed state of ClusterIP
+type ClusterIPStatus struct {
+	// State signifies current state of Module CR.
+	// Value can be one of ("Ready", "Processing", "Error", "Deleting").
+	// +kubebuilder:validation:Enum=Processing;Deleting;Ready;Error
+	State string `json:"state,omitempty"`
+	Info  string `json:"info,omitempty"`
+
+	// NodeIPs is keyed by the node label, so that every worker applies its own entry.
+	//+listType=map
+	//+listMapKey=nodeLabel
+	NodeIPs []NodeIP `json:"nodeIPs,omitempty"`
+
+	//+listType=map
+	//+listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	Notifications []NotificationStatus `json:"notifications,omitempty"`
+
+	// LastRefreshRequest is the value of the refresh-requested-at annotation handled last.
+	LastRefreshRequest string `json:"lastRefreshRequest,omitempty"`
+	// LastRefreshTime is when the last refresh request was handled. Node IPs updated before are determined again.
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+}
+
+// NotificationStatus records the delivery state of a single notification target.
+type NotificationStatus struct {
+	// Name identifies the target in the spec, webhooks[<index>] or cloudEvents, so that targets with the same URL
+	// have their own delivery state.
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	// Delivered is the last state successfully delivered to the target.
+	Delivered []DeliveredNodeIP `json:"delivered,omitempty"`
+	// Failures counts consecutive failed deliveries.
+	Failures        int          `json:"failures,omitempty"`
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	LastError       string       `json:"lastError,omitempty"`
+}
+
+// DeliveredNodeIP is what a notification target knows about a node label. It holds only what is compared
+// to find the changes to deliver, so that the status doesn't grow with every target.
+type DeliveredNodeIP struct {
+	NodeLabel string   `json:"nodeLabel"`
+	IPs       []string `json:"ips,omitempty"`
+	// Error is kept, so that a failure is delivered only once.
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.nodeSpreadLabel`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterIP is the Schema for the clusterips API
+type ClusterIP struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	//+kubebuilder:default={nodeSpreadLabel:"topology.kubernetes.io/zone"}
+	Spec   ClusterIPSpec   `json:"spec,omitempty"`
+	Status ClusterIPStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterIPList contains a list of ClusterIP
+type ClusterIPList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterIP `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterIP{}, &ClusterIPList{})
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const DefaultNodeSpreadLabel = "topology.kubernetes.io/zone"
+
+// DefaultRefreshInterval is how long the node IPs are used before they are determined again.
+const DefaultRefreshInterval = 24 * time.Hour
+
+// DefaultWorkerStartJitter is the duration over which the starts of the workers are spread.
+const DefaultWorkerStartJitter = 10 * time.Second
+
+// MinRefreshInterval protects the cluster from constantly starting worker pods.
+const MinRefreshInterval = time.Minute
+
+// log is for logging in this package.
+var clusteriplog = logf.Log.WithName("clusterip-resource")
+
+// ClusterIPWebhook defaults and validates ClusterIP and GlobalClusterIP resources. It needs a client
+// to check the cluster nodes and the other ClusterIP resources.
+// +kubebuilder:object:generate=false
+type ClusterIPWebhook struct {
+	Client client.Reader
+}
+
+func (w *ClusterIPWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if w.Client == nil {
+		w.Client = mgr.GetClient()
+	}
+	for _, obj := range []ClusterIPObject{&ClusterIP{}, &GlobalClusterIP{}} {
+		err := ctrl.NewWebhookManagedBy(mgr).
+			For(obj).
+			WithDefaulter(w).
+			WithValidator(w).
+			Complete()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-operator-kyma-project-io-v1beta1-clusterip,mutating=true,failurePolicy=fail,sideEffects=None,groups=operator.kyma-project.io,resources=clusterips,verbs=create;update,versions=v1beta1,name=mclusterip.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/mutate-operator-kyma-project-io-v1beta1-globalclusterip,mutating=true,failurePolicy=fail,sideEffects=None,groups=operator.kyma-project.io,resources=globalclusterips,verbs=create;update,versions=v1beta1,name=mglobalclusterip.kb.io,admissionReviewVersions=v1
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type
+func (w *ClusterIPWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	r, ok := obj.(ClusterIPObject)
+	if !ok {
+		return fmt.Errorf("expected ClusterIP or GlobalClusterIP, got %T", obj)
+	}
+	clusteriplog.Info("default", "name", r.GetName())
+	spec := r.GetSpec()
+	spec.NodeSpreadLabel = strings.TrimSpace(spec.NodeSpreadLabel)
+	if spec.NodeSpreadLabel == "" {
+		spec.NodeSpreadLabel = DefaultNodeSpreadLabel
+	}
+	if spec.Notifications != nil && spec.Notifications.CloudEvents != nil && spec.Notifications.CloudEvents.Mode == "" {
+		spec.Notifications.CloudEvents.Mode = "binary"
+	}
+	if spec.DNSEndpoint != nil && spec.DNSEndpoint.TTL == 0 {
+		spec.DNSEndpoint.TTL = 300
+	}
+	if spec.RefreshInterval == nil {
+		spec.RefreshInterval = &metav1.Duration{Duration: DefaultRefreshInterval}
+	}
+	if spec.WorkerStartJitter == nil {
+		spec.WorkerStartJitter = &metav1.Duration{Duration: DefaultWorkerStartJitter}
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-operator-kyma-project-io-v1beta1-clusterip,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.kyma-project.io,resources=clusterips,verbs=create;update,versions=v1beta1,name=vclusterip.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-operator-kyma-project-io-v1beta1-globalclusterip,mutating=false,failurePolicy=fail,sideEffects=None,groups=operator.kyma-project.io,resources=globalclusterips,verbs=create;update,versions=v1beta1,name=vglobalclusterip.kb.io,admissionReviewVersions=v1
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *ClusterIPWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	r, ok := obj.(ClusterIPObject)
+	if !ok {
+		return nil, fmt.Errorf("expected ClusterIP or GlobalClusterIP, got %T", obj)
+	}
+	clusteriplog.Info("validate create", "name", r.GetName())
+	return w.validate(ctx, r)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *ClusterIPWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	r, ok := newObj.(ClusterIPObject)
+	if !ok {
+		return nil, fmt.Errorf("expected ClusterIP or GlobalClusterIP, got %T", newObj)
+	}
+	clusteriplog.Info("validate update", "name", r.GetName())
+	return w.validate(ctx, r)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *ClusterIPWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (w *ClusterIPWebhook) validate(ctx context.Context, r ClusterIPObject) (admission.Warnings, error) {
+	spec := r.GetSpec()
+	var allErrs field.ErrorList
+	var warnings admission.Warnings
+	specPath := field.NewPath("spec")
+
+	labelPath := specPath.Child("nodeSpreadLabel")
+	if msgs := validation.IsQualifiedName(spec.NodeSpreadLabel); len(msgs) > 0 {
+		allErrs = append(allErrs, field.Invalid(labelPath, spec.NodeSpreadLabel, strings.Join(msgs, "; ")))
+	} else {
+		eligible, err := w.eligibleNodes(ctx, spec.NodeSpreadLabel)
+		if err != nil {
+			return nil, apierrors.NewInternalError(err)
+		}
+		if eligible == 0 {
+			warnings = append(warnings, fmt.Sprintf("no schedulable node has the label %q, the resource will not become ready until such node exists", spec.NodeSpreadLabel))
+		}
+		conflict, err := w.conflictingClusterIP(ctx, r)
+		if err != nil {
+			return nil, apierrors.NewInternalError(err)
+		}
+		if conflict != "" {
+			allErrs = append(allErrs, field.Duplicate(labelPath, fmt.Sprintf("%s is already used by %s", spec.NodeSpreadLabel, conflict)))
+		}
+	}
+
+	if n := spec.Notifications; n != nil {
+		for i, webhook := range n.Webhooks {
+			allErrs = append(allErrs, validateURL(specPath.Child("notifications", "webhooks").Index(i).Child("url"), webhook.URL)...)
+		}
+		if n.CloudEvents != nil {
+			allErrs = append(allErrs, validateURL(specPath.Child("notifications", "cloudEvents", "sink"), n.CloudEvents.Sink)...)
+		}
+	}
+	if d := spec.DNSEndpoint; d != nil {
+		dnsPath := specPath.Child("dnsEndpoint")
+		if msgs := validation.IsDNS1123Subdomain(strings.TrimPrefix(d.DNSName, "*.")); len(msgs) > 0 {
+			allErrs = append(allErrs, field.Invalid(dnsPath.Child("dnsName"), d.DNSName, strings.Join(msgs, "; ")))
+		}
+		if d.TTL < 0 {
+			allErrs = append(allErrs, field.Invalid(dnsPath.Child("ttl"), d.TTL, "must not be negative"))
+		}
+	}
+	names := map[string]bool{}
+	for i, p := range spec.Providers {
+		providerPath := specPath.Child("providers").Index(i)
+		if p.Name == "" {
+			allErrs = append(allErrs, field.Required(providerPath.Child("name"), "must not be empty"))
+		} else if names[p.Name] {
+			allErrs = append(allErrs, field.Duplicate(providerPath.Child("name"), p.Name))
+		}
+		names[p.Name] = true
+		allErrs = append(allErrs, validateURL(providerPath.Child("url"), p.URL)...)
+		if p.Format == ResponseFormatRegex {
+			if p.Regex == "" {
+				allErrs = append(allErrs, field.Required(providerPath.Child("regex"), "must be set for the regex format"))
+			} else if _, err := regexp.Compile(p.Regex); err != nil {
+				allErrs = append(allErrs, field.Invalid(providerPath.Child("regex"), p.Regex, err.Error()))
+			}
+		}
+		if p.PublicKey != "" {
+			if p.Format != ResponseFormatEcho {
+				allErrs = append(allErrs, field.Forbidden(providerPath.Child("publicKey"), "is only supported for the echo format"))
+			} else if _, err := p.Ed25519PublicKey(); err != nil {
+				allErrs = append(allErrs, field.Invalid(providerPath.Child("publicKey"), p.PublicKey, err.Error()))
+			}
+		}
+		if p.Budget != nil {
+			if p.Budget.Requests < 1 {
+				allErrs = append(allErrs, field.Invalid(providerPath.Child("budget", "requests"), p.Budget.Requests, "must be at least 1"))
+			}
+			if p.Budget.Period.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(providerPath.Child("budget", "period"), p.Budget.Period.Duration.String(), "must be positive"))
+			}
+		}
+		allErrs = append(allErrs, validateHTTPConfig(providerPath, &p.HTTPConfig)...)
+	}
+	if spec.HTTP != nil {
+		allErrs = append(allErrs, validateHTTPConfig(specPath.Child("http"), spec.HTTP)...)
+	}
+	for i, cidr := range spec.ExpectedCIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("expectedCIDRs").Index(i), cidr, err.Error()))
+		}
+	}
+	if spec.WorkerStartJitter != nil && spec.WorkerStartJitter.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("workerStartJitter"), spec.WorkerStartJitter.Duration.String(), "must not be negative"))
+	}
+	if spec.RefreshInterval != nil && spec.RefreshInterval.Duration < MinRefreshInterval {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("refreshInterval"), spec.RefreshInterval.Duration.String(), fmt.Sprintf("must be at least %s", MinRefreshInterval)))
+	}
+
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+	return warnings, apierrors.NewInvalid(r.GetObjectKind().GroupVersionKind().GroupKind(), r.GetName(), allErrs)
+}
+
+func validateHTTPConfig(path *field.Path, c *HTTPConfig) field.ErrorList {
+	var allErrs field.ErrorList
+	if c.ProxyURL != "" {
+		allErrs = append(allErrs, validateURL(path.Child("proxyURL"), c.ProxyURL)...)
+		if c.ProxyURLSecretRef != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("proxyURLSecretRef"), "must not be set together with proxyURL"))
+		}
+	}
+	if c.CABundle != nil && (c.CABundle.SecretKeyRef == nil) == (c.CABundle.ConfigMapKeyRef == nil) {
+		allErrs = append(allErrs, field.Invalid(path.Child("caBundle"), "", "exactly one of secretKeyRef and configMapKeyRef must be set"))
+	}
+	for i, h := range c.HeadersFrom {
+		if h.Name == "" {
+			allErrs = append(allErrs, field.Required(path.Child("headersFrom").Index(i).Child("name"), "must not be empty"))
+		}
+	}
+	return allErrs
+}
+
+func validateURL(path *field.Path, value string) field.ErrorList {
+	u, err := url.ParseRequestURI(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, err.Error())}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return field.ErrorList{field.Invalid(path, value, "scheme must be http or https")}
+	}
+	if u.Host == "" {
+		return field.ErrorList{field.Invalid(path, value, "host must not be empty")}
+	}
+	return nil
+}
+
+// eligibleNodes counts the nodes without taints having the label, the same nodes the controller deploys workers to.
+func (w *ClusterIPWebhook) eligibleNodes(ctx context.Context, label string) (int, error) {
+	var nodes corev1.NodeList
+	if err := w.Client.List(ctx, &nodes, client.HasLabels{label}); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, n := range nodes.Items {
+		if len(n.Spec.Taints) == 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// conflictingClusterIP returns the name of another ClusterIP or GlobalClusterIP with the same node spread label.
+// Such resources would share the worker pods and overwrite each other results.
+func (w *ClusterIPWebhook) conflictingClusterIP(ctx context.Context, r ClusterIPObject) (string, error) {
+	var list ClusterIPList
+	if err := w.Client.List(ctx, &list); err != nil {
+		return "", err
+	}
+	var globalList GlobalClusterIPList
+	if err := w.Client.List(ctx, &globalList); err != nil {
+		return "", err
+	}
+	_, global := r.(*GlobalClusterIP)
+	for _, item := range list.Items {
+		if (global || item.Namespace != r.GetNamespace() || item.Name != r.GetName()) && item.Spec.NodeSpreadLabel == r.GetSpec().NodeSpreadLabel {
+			return "ClusterIP " + item.Namespace + "/" + item.Name, nil
+		}
+	}
+	for _, item := range globalList.Items {
+		if (!global || item.Name != r.GetName()) && item.Spec.NodeSpreadLabel == r.GetSpec().NodeSpreadLabel {
+			return "GlobalClusterIP " + item.Name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterIPWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	AddToScheme(scheme)
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{DefaultNodeSpreadLabel: "a"}}}
+	existing := &ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: "default"}, Spec: ClusterIPSpec{NodeSpreadLabel: "kubernetes.io/hostname"}}
+	existingGlobal := &GlobalClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "existing"}, Spec: ClusterIPSpec{NodeSpreadLabel: "kubernetes.io/os"}}
+	w := &ClusterIPWebhook{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(node, existing, existingGlobal).Build()}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		spec     ClusterIPSpec
+		valid    bool
+		warnings int
+	}{
+		{name: "defaults", spec: ClusterIPSpec{}, valid: true},
+		{name: "missing label", spec: ClusterIPSpec{NodeSpreadLabel: "example.com/missing"}, valid: true, warnings: 1},
+		{name: "invalid label", spec: ClusterIPSpec{NodeSpreadLabel: "topology.kubernetes.io/zone!"}, valid: false},
+		{name: "conflict", spec: ClusterIPSpec{NodeSpreadLabel: "kubernetes.io/hostname"}, valid: false, warnings: 1},
+		{name: "conflict with global", spec: ClusterIPSpec{NodeSpreadLabel: "kubernetes.io/os"}, valid: false, warnings: 1},
+		{name: "invalid webhook", spec: ClusterIPSpec{Notifications: &Notifications{Webhooks: []WebhookNotification{{URL: "ftp://example.com"}}}}, valid: false},
+		{name: "invalid sink", spec: ClusterIPSpec{Notifications: &Notifications{CloudEvents: &CloudEventsNotification{Sink: "http://"}}}, valid: false},
+		{name: "invalid dns name", spec: ClusterIPSpec{DNSEndpoint: &DNSEndpoint{DNSName: "egress_ip.example.com"}}, valid: false},
+		{name: "valid dns name", spec: ClusterIPSpec{DNSEndpoint: &DNSEndpoint{DNSName: "egress.example.com"}}, valid: true},
+		{name: "too short refresh interval", spec: ClusterIPSpec{RefreshInterval: &metav1.Duration{Duration: time.Second}}, valid: false},
+		{name: "valid refresh interval", spec: ClusterIPSpec{RefreshInterval: &metav1.Duration{Duration: time.Hour}}, valid: true},
+		{name: "valid providers", spec: ClusterIPSpec{
+			Providers: []Provider{{Name: "a", URL: "https://a.example.com"}, {Name: "b", URL: "https://b.example.com", HTTPConfig: HTTPConfig{ProxyURL: "http://proxy:3128"}}},
+			HTTP:      &HTTPConfig{CABundle: &CABundleSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"}}},
+		}, valid: true},
+		{name: "duplicate provider", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com"}, {Name: "a", URL: "https://b.example.com"}}}, valid: false},
+		{name: "valid regex", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Format: ResponseFormatRegex, Regex: `ip=(\S+)`}}}, valid: true},
+		{name: "invalid regex", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Format: ResponseFormatRegex, Regex: `ip=(`}}}, valid: false},
+		{name: "missing regex", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Format: ResponseFormatRegex}}}, valid: false},
+		{name: "echo public key", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Format: ResponseFormatEcho, PublicKey: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}}}, valid: true},
+		{name: "invalid public key", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Format: ResponseFormatEcho, PublicKey: "AAAA"}}}, valid: false},
+		{name: "public key without echo format", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", PublicKey: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="}}}, valid: false},
+		{name: "valid budget", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Budget: &ProviderBudget{Requests: 100, Period: metav1.Duration{Duration: time.Hour}}}}}, valid: true},
+		{name: "empty budget", spec: ClusterIPSpec{Providers: []Provider{{Name: "a", URL: "https://a.example.com", Budget: &ProviderBudget{}}}}, valid: false},
+		{name: "expected CIDRs", spec: ClusterIPSpec{ExpectedCIDRs: []string{"3.120.0.0/16", "2a05:d014::/32"}}, valid: true},
+		{name: "invalid expected CIDR", spec: ClusterIPSpec{ExpectedCIDRs: []string{"3.120.1.2"}}, valid: false},
+		{name: "negative jitter", spec: ClusterIPSpec{WorkerStartJitter: &metav1.Duration{Duration: -time.Second}}, valid: false},
+		{name: "invalid proxy", spec: ClusterIPSpec{HTTP: &HTTPConfig{ProxyURL: "proxy:3128"}}, valid: false},
+		{name: "ambiguous ca bundle", spec: ClusterIPSpec{HTTP: &HTTPConfig{CABundle: &CABundleSource{}}}, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}, Spec: tt.spec}
+			if err := w.Default(ctx, r); err != nil {
+				t.Fatal(err)
+			}
+			warnings, err := w.ValidateCreate(ctx, r)
+			if tt.valid && err != nil {
+				t.Errorf("expected valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected error")
+			}
+			if len(warnings) != tt.warnings {
+				t.Errorf("expected %d warnings, got %v", tt.warnings, warnings)
+			}
+		})
+	}
+
+	global := &GlobalClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "existing"}, Spec: ClusterIPSpec{NodeSpreadLabel: "kubernetes.io/os"}}
+	if _, err := w.ValidateUpdate(ctx, existingGlobal, global); err != nil {
+		t.Errorf("expected update of the same GlobalClusterIP to be valid, got %v", err)
+	}
+	global.Name = "other"
+	if _, err := w.ValidateCreate(ctx, global); err == nil {
+		t.Error("expected conflict with GlobalClusterIP")
+	}
+}
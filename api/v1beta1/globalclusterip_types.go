@@ -0,0 +1,78 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.nodeSpreadLabel`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// GlobalClusterIP is the cluster-scoped variant of ClusterIP. The node IPs are a cluster-wide fact,
+// so there should be one well-known GlobalClusterIP per node spread label.
+type GlobalClusterIP struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	//+kubebuilder:default={nodeSpreadLabel:"topology.kubernetes.io/zone"}
+	Spec   ClusterIPSpec   `json:"spec,omitempty"`
+	Status ClusterIPStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GlobalClusterIPList contains a list of GlobalClusterIP
+type GlobalClusterIPList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GlobalClusterIP `json:"items"`
+}
+
+// ClusterIPObject is implemented by ClusterIP and GlobalClusterIP, which share the spec and status.
+// +kubebuilder:object:generate=false
+type ClusterIPObject interface {
+	client.Object
+	GetSpec() *ClusterIPSpec
+	GetStatus() *ClusterIPStatus
+}
+
+func (c *ClusterIP) GetSpec() *ClusterIPSpec {
+	return &c.Spec
+}
+
+func (c *ClusterIP) GetStatus() *ClusterIPStatus {
+	return &c.Status
+}
+
+func (c *GlobalClusterIP) GetSpec() *ClusterIPSpec {
+	return &c.Spec
+}
+
+func (c *GlobalClusterIP) GetStatus() *ClusterIPStatus {
+	return &c.Status
+}
+
+func init() {
+	SchemeBuilder.Register(&GlobalClusterIP{}, &GlobalClusterIPList{})
+}
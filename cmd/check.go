@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/ip"
+	"github.com/kyma-project/cluster-ip/internal/worker"
+)
+
+// checkResult is the outcome of the provider quorum printed by the check command.
+type checkResult struct {
+	IP       string              `json:"ip,omitempty"`
+	Quorum   int                 `json:"quorum"`
+	Metadata *v1beta1.IPMetadata `json:"metadata,omitempty"`
+	Evidence []v1beta1.Evidence  `json:"evidence"`
+	Health   []checkHealth       `json:"health,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// checkHealth is the health score of a provider, which the workers use to prefer healthy providers.
+type checkHealth struct {
+	Provider            string  `json:"provider"`
+	Score               float64 `json:"score"`
+	LatencyMilliseconds int64   `json:"latencyMilliseconds"`
+}
+
+func newCheckResult(quorum int, result ip.Result, err error) checkResult {
+	check := checkResult{IP: result.IP, Quorum: quorum, Metadata: worker.Metadata(result.Metadata), Evidence: []v1beta1.Evidence{}}
+	check.Evidence = append(check.Evidence, worker.Evidence(result)...)
+	for _, h := range result.Health {
+		check.Health = append(check.Health, checkHealth{Provider: h.Provider, Score: h.Score(), LatencyMilliseconds: h.Latency.Milliseconds()})
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+func printCheck(w io.Writer, output string, check checkResult) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(check)
+	case "yaml":
+		out, err := yaml.Marshal(check)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	case "text":
+		if check.Error != "" {
+			fmt.Fprintf(w, "Error: %s\n\n", check.Error)
+		} else {
+			fmt.Fprintf(w, "IP: %s (quorum %d)\n", check.IP, check.Quorum)
+			if m := check.Metadata; m != nil {
+				fmt.Fprintf(w, "Location: %s %s, AS%d %s (from %s)\n", m.Country, m.City, m.ASN, m.Org, m.Source)
+			}
+			fmt.Fprintln(w)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROVIDER\tIP\tSTATUS\tREASON\tERROR")
+		for _, e := range check.Evidence {
+			status := ""
+			if e.StatusCode != 0 {
+				status = fmt.Sprint(e.StatusCode)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Provider, e.IP, status, e.Reason, e.Error)
+		}
+		if len(check.Health) > 0 {
+			fmt.Fprintln(tw, "\nPROVIDER\tSCORE\tLATENCY")
+			for _, h := range check.Health {
+				fmt.Fprintf(tw, "%s\t%.2f\t%dms\n", h.Provider, h.Score, h.LatencyMilliseconds)
+			}
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of: text, json, yaml", output)
+	}
+}
+
+// runCheck asks all IP providers for the egress IP of the current host and prints their answers.
+// It returns the exit code, which is 1 if the quorum was not reached.
+func runCheck(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	var output string
+	var quorum int
+	var allow string
+	fs.StringVar(&output, "output", "text", "Output format: text, json or yaml")
+	fs.StringVar(&output, "o", "text", "Shorthand for --output")
+	fs.IntVar(&quorum, "quorum", 2, "The number of providers which must return the same IP")
+	fs.StringVar(&allow, "allow", "", "Comma separated classes of non-public addresses to accept, like Private or CGNAT")
+	fs.Parse(args)
+
+	policy, err := ip.ParsePolicy(strings.Split(allow, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	result, err := ip.Check(quorum, ip.NewTracker(ip.DefaultFailureThreshold, ip.DefaultCoolDown), policy)
+	check := newCheckResult(quorum, result, err)
+	if err := printCheck(w, output, check); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if check.Error != "" {
+		return 1
+	}
+	return 0
+}
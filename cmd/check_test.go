@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/kyma-project/cluster-ip/internal/ip"
+)
+
+func TestPrintCheck(t *testing.T) {
+	check := newCheckResult(2, ip.Result{IP: "1.2.3.4", Evidence: []ip.Evidence{
+		{Provider: "ipinfo.io", IP: "1.2.3.4"},
+		{Provider: "ifconfig.me", Err: errors.New("timeout")},
+		{Provider: "jsonip.com", IP: "1.2.3.4"},
+	}, Health: []ip.Health{{Provider: "ifconfig.me", Failures: 1, ConsecutiveFailures: 1, Latency: 5 * time.Second}}}, nil)
+
+	var buf bytes.Buffer
+	if err := printCheck(&buf, "text", check); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"IP: 1.2.3.4 (quorum 2)", "ifconfig.me", "timeout", "0.17", "5000ms"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in text output:\n%s", s, buf.String())
+		}
+	}
+
+	for output, unmarshal := range map[string]func([]byte, any) error{"json": json.Unmarshal, "yaml": func(b []byte, v any) error { return yaml.Unmarshal(b, v) }} {
+		buf.Reset()
+		if err := printCheck(&buf, output, check); err != nil {
+			t.Fatal(err)
+		}
+		var parsed checkResult
+		if err := unmarshal(buf.Bytes(), &parsed); err != nil {
+			t.Fatalf("%s: %v", output, err)
+		}
+		if parsed.IP != "1.2.3.4" || len(parsed.Evidence) != 3 || parsed.Evidence[1].Error != "timeout" {
+			t.Errorf("%s: unexpected result %+v", output, parsed)
+		}
+	}
+
+	if err := printCheck(&buf, "xml", check); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestPrintCheckError(t *testing.T) {
+	check := newCheckResult(2, ip.Result{}, errors.New("only 0 of 2 required services returned valid IP"))
+	var buf bytes.Buffer
+	if err := printCheck(&buf, "text", check); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Error: only 0 of 2") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
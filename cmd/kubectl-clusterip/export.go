@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var exportFormats = []string{"plain", "cidr", "json", "terraform", "networkpolicy"}
+
+// cidrs returns the IPs as single host prefixes.
+func cidrs(ips []string) []string {
+	result := []string{}
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		result = append(result, netip.PrefixFrom(addr, addr.BitLen()).String())
+	}
+	return result
+}
+
+// networkPolicy returns a NetworkPolicy allowing ingress to all pods of the namespace from the IPs.
+// It is meant to be applied in another cluster which should accept traffic from this one.
+// Without any IP it returns an error, because a rule without peers would allow ingress from everywhere.
+func networkPolicy(name string, ips []string) (*networkingv1.NetworkPolicy, error) {
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, cidr := range cidrs(ips) {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("%s has no IPs to allow", name)
+	}
+	return &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-from-" + name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: peers}},
+		},
+	}, nil
+}
+
+// export writes the IPs of the named ClusterIP in the given format.
+func export(w io.Writer, format, name, variable string, ips []string) error {
+	switch format {
+	case "plain":
+		for _, ip := range ips {
+			fmt.Fprintln(w, ip)
+		}
+	case "cidr":
+		for _, cidr := range cidrs(ips) {
+			fmt.Fprintln(w, cidr)
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ips)
+	case "terraform":
+		quoted := make([]string, 0, len(ips))
+		for _, cidr := range cidrs(ips) {
+			quoted = append(quoted, fmt.Sprintf("%q", cidr))
+		}
+		fmt.Fprintf(w, "%s = [%s]\n", variable, strings.Join(quoted, ", "))
+	case "networkpolicy":
+		policy, err := networkPolicy(name, ips)
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(policy)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		return fmt.Errorf("unknown format %q, expected one of: %s", format, strings.Join(exportFormats, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestExport(t *testing.T) {
+	ips := []string{"1.2.3.4", "2001:db8::1"}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"plain", "1.2.3.4\n2001:db8::1\n"},
+		{"cidr", "1.2.3.4/32\n2001:db8::1/128\n"},
+		{"json", "[\n  \"1.2.3.4\",\n  \"2001:db8::1\"\n]\n"},
+		{"terraform", "cluster_ips = [\"1.2.3.4/32\", \"2001:db8::1/128\"]\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := export(&buf, tt.format, "sample", "cluster_ips", ips); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.expected, buf.String())
+		}
+	}
+	if err := export(&bytes.Buffer{}, "xml", "sample", "cluster_ips", ips); err == nil || !strings.Contains(err.Error(), "networkpolicy") {
+		t.Errorf("expected error listing the formats, got %v", err)
+	}
+}
+
+func TestExportNetworkPolicy(t *testing.T) {
+	var buf bytes.Buffer
+	if err := export(&buf, "networkpolicy", "sample", "", []string{"1.2.3.4", "invalid"}); err != nil {
+		t.Fatal(err)
+	}
+	var policy networkingv1.NetworkPolicy
+	if err := yaml.UnmarshalStrict(buf.Bytes(), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Kind != "NetworkPolicy" || policy.Name != "allow-from-sample" {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	from := policy.Spec.Ingress[0].From
+	if len(from) != 1 || from[0].IPBlock.CIDR != "1.2.3.4/32" {
+		t.Errorf("unexpected peers %+v", from)
+	}
+}
+
+func TestExportNetworkPolicyWithoutIPs(t *testing.T) {
+	for _, ips := range [][]string{nil, {"invalid"}} {
+		var buf bytes.Buffer
+		if err := export(&buf, "networkpolicy", "sample", "", ips); err == nil {
+			t.Errorf("expected error for %v, got policy %s", ips, buf.String())
+		}
+		if buf.Len() > 0 {
+			t.Errorf("expected no output for %v, got %s", ips, buf.String())
+		}
+	}
+}
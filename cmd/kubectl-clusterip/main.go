@@ -0,0 +1,274 @@
+// kubectl-clusterip is a kubectl plugin showing and exporting the IPs determined by the cluster-ip operator.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+)
+
+const usage = `Usage: kubectl clusterip COMMAND [NAME] [flags]
+
+Commands:
+  list                 List ClusterIP and GlobalClusterIP resources with their IPs
+  refresh NAME         Request an immediate re-discovery of the IPs
+  evidence NAME        Show the answers of the IP providers per node label
+  history NAME         Show the IP changes recorded as events
+  export NAME          Print the IPs in the format given by --format (plain, cidr, json, terraform, networkpolicy)
+
+Use --global to address a GlobalClusterIP and "kubectl clusterip COMMAND -h" to list the flags of a command.
+`
+
+type options struct {
+	kubeconfig    string
+	context       string
+	namespace     string
+	allNamespaces bool
+	global        bool
+	format        string
+	variable      string
+}
+
+func newFlagSet(command string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file")
+	fs.StringVar(&opts.context, "context", "", "The kubeconfig context to use")
+	fs.StringVar(&opts.namespace, "namespace", "", "The namespace of the ClusterIP")
+	fs.StringVar(&opts.namespace, "n", "", "Shorthand for --namespace")
+	switch command {
+	case "list":
+		fs.BoolVar(&opts.allNamespaces, "all-namespaces", false, "List ClusterIPs in all namespaces")
+		fs.BoolVar(&opts.allNamespaces, "A", false, "Shorthand for --all-namespaces")
+	case "export":
+		fs.StringVar(&opts.format, "format", "plain", "Output format: "+strings.Join(exportFormats, ", "))
+		fs.StringVar(&opts.variable, "var-name", "cluster_egress_ips", "The variable name used by the terraform format")
+		fallthrough
+	default:
+		fs.BoolVar(&opts.global, "global", false, "Address a cluster-scoped GlobalClusterIP")
+	}
+	return fs
+}
+
+// parse parses the flags also when they follow the positional arguments, like kubectl does.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func newClient(opts *options) (client.Client, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = opts.kubeconfig
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: opts.context})
+	cfg, err := loader.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace := opts.namespace
+	if namespace == "" {
+		if namespace, _, err = loader.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	return c, namespace, err
+}
+
+func get(ctx context.Context, c client.Client, namespace, name string, global bool) (v1beta1.ClusterIPObject, error) {
+	if global {
+		obj := &v1beta1.GlobalClusterIP{}
+		return obj, c.Get(ctx, types.NamespacedName{Name: name}, obj)
+	}
+	obj := &v1beta1.ClusterIP{}
+	return obj, c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj)
+}
+
+func kind(obj v1beta1.ClusterIPObject) string {
+	if obj.GetNamespace() == "" {
+		return "globalclusterip"
+	}
+	return "clusterip"
+}
+
+func list(ctx context.Context, w io.Writer, c client.Client, namespace string, all bool) error {
+	var clusterIPs v1beta1.ClusterIPList
+	var listOpts []client.ListOption
+	if !all {
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	if err := c.List(ctx, &clusterIPs, listOpts...); err != nil {
+		return err
+	}
+	var globalClusterIPs v1beta1.GlobalClusterIPList
+	if err := c.List(ctx, &globalClusterIPs); err != nil {
+		return err
+	}
+	var objects []v1beta1.ClusterIPObject
+	for i := range clusterIPs.Items {
+		objects = append(objects, &clusterIPs.Items[i])
+	}
+	for i := range globalClusterIPs.Items {
+		objects = append(objects, &globalClusterIPs.Items[i])
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tLABEL\tSTATE\tIPS")
+	for _, obj := range objects {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", kind(obj), obj.GetNamespace(), obj.GetName(),
+			obj.GetSpec().NodeSpreadLabel, obj.GetStatus().State, strings.Join(notification.IPs(obj.GetStatus().NodeIPs), ","))
+	}
+	return tw.Flush()
+}
+
+func refresh(ctx context.Context, w io.Writer, c client.Client, obj v1beta1.ClusterIPObject) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	annotations[v1beta1.RefreshRequestedAnnotation] = now
+	obj.SetAnnotations(annotations)
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s/%s refresh requested at %s\n", kind(obj), obj.GetName(), now)
+	return nil
+}
+
+func evidence(w io.Writer, obj v1beta1.ClusterIPObject) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(tw, "NODE LABEL\tADDRESSES\tUPDATED\tPROVIDER\tIP\tERROR")
+	for _, n := range obj.GetStatus().NodeIPs {
+		updated := ""
+		if !n.LastUpdateTime.IsZero() {
+			updated = n.LastUpdateTime.UTC().Format(time.RFC3339)
+		}
+		prefix := fmt.Sprintf("%s\t%s\t%s", n.NodeLabel, strings.Join(n.IPs(), ","), updated)
+		if len(n.Evidence) == 0 {
+			fmt.Fprintf(tw, "%s\t\t\t%s\n", prefix, n.Error)
+		}
+		for _, e := range n.Evidence {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", prefix, e.Provider, e.IP, e.Error)
+			prefix = "\t\t"
+		}
+	}
+	return tw.Flush()
+}
+
+func history(ctx context.Context, w io.Writer, c client.Client, obj v1beta1.ClusterIPObject) error {
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		// events of cluster-scoped objects are recorded in the default namespace
+		namespace = corev1.NamespaceDefault
+	}
+	var events corev1.EventList
+	if err := c.List(ctx, &events, client.InNamespace(namespace), client.MatchingFields{"involvedObject.uid": string(obj.GetUID())}); err != nil {
+		return err
+	}
+	slices.SortFunc(events.Items, func(a, b corev1.Event) int {
+		return eventTime(a).Compare(eventTime(b))
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTYPE\tREASON\tMESSAGE")
+	for _, e := range events.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", eventTime(e).UTC().Format(time.RFC3339), e.Type, e.Reason, e.Message)
+	}
+	return tw.Flush()
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	return e.EventTime.Time
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(w, usage)
+		return nil
+	}
+	command := args[0]
+	opts := &options{}
+	fs := newFlagSet(command, opts)
+	positional, err := parse(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if command == "list" {
+		if len(positional) != 0 {
+			return fmt.Errorf("list takes no arguments")
+		}
+		c, namespace, err := newClient(opts)
+		if err != nil {
+			return err
+		}
+		return list(ctx, w, c, namespace, opts.allNamespaces)
+	}
+	if !slices.Contains([]string{"refresh", "evidence", "history", "export"}, command) {
+		return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%s requires exactly one NAME argument", command)
+	}
+	c, namespace, err := newClient(opts)
+	if err != nil {
+		return err
+	}
+	obj, err := get(ctx, c, namespace, positional[0], opts.global)
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "refresh":
+		return refresh(ctx, w, c, obj)
+	case "evidence":
+		return evidence(w, obj)
+	case "history":
+		return history(ctx, w, c, obj)
+	default:
+		return export(w, opts.format, obj.GetName(), opts.variable, notification.IPs(obj.GetStatus().NodeIPs))
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	operatorv1beta1 "github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/controller"
+	"github.com/kyma-project/cluster-ip/internal/geoip"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+	"github.com/kyma-project/cluster-ip/internal/worker"
+)
+
+// serviceAccountNamespaceFile holds the namespace of the pod. Worker pods of older versions have
+// no MY_POD_NAMESPACE environment variable, but run in the operator namespace.
+var serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// legacyWorkerArgs returns the worker arguments for a worker pod started with --node by an older version.
+// The system namespace defaults to the namespace of the pod, so that the secrets and config maps
+// referenced from a GlobalClusterIP are found.
+func legacyWorkerArgs(node, nodeSpreadLabel, systemNamespace string) []string {
+	if systemNamespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+			systemNamespace = strings.TrimSpace(string(data))
+		}
+	}
+	return []string{"--node", node, "--nodeSpreadLabel", nodeSpreadLabel, "--system-namespace", systemNamespace}
+}
+
+// runManager runs the controller manager. The --node flag selects the worker mode,
+// so that worker pods created by older versions keep working.
+func runManager(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	var metricsAddr string
+	var enableLeaderElection bool
+	var probeAddr string
+	var node string
+	var nodeSpreadLabel string
+	var systemNamespace string
+	var workerImage string
+	var allowedWorkerImages string
+	var geoIPDatabases string
+	fs.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	fs.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	fs.BoolVar(&enableLeaderElection, "leader-elect", false,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.StringVar(&node, "node", "", "The node where controller pod is deployed to")
+	fs.StringVar(&nodeSpreadLabel, "nodeSpreadLabel", "", "The node label used to spread workers")
+	fs.StringVar(&systemNamespace, "system-namespace", "", "The namespace where controller helper pods should be deployed")
+	fs.StringVar(&workerImage, "worker-image", os.Getenv("IMAGE_NAME"), "The image of the worker pods. Defaults to the IMAGE_NAME environment variable or the image of the manager container")
+	fs.StringVar(&allowedWorkerImages, "allowed-worker-images", "", "Comma separated image repositories, like registry.example.com/cluster-ip, which the spec.workerImage of a ClusterIP may use. Without them spec.workerImage is rejected")
+	fs.StringVar(&geoIPDatabases, "geoip-database", "", "Comma separated paths of MaxMind DB files, like GeoLite2-City.mmdb and GeoLite2-ASN.mmdb, to look up the location and network of the node IPs in")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+	fs.Parse(args)
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	if systemNamespace == "" {
+		systemNamespace = os.Getenv("MY_POD_NAMESPACE")
+	}
+	if node != "" {
+		os.Exit(worker.Main(legacyWorkerArgs(node, nodeSpreadLabel, systemNamespace)))
+	}
+
+	var databases geoip.Databases
+	if geoIPDatabases != "" {
+		var err error
+		if databases, err = geoip.OpenAll(strings.Split(geoIPDatabases, ",")); err != nil {
+			setupLog.Error(err, "unable to read GeoIP database")
+			os.Exit(1)
+		}
+	}
+
+	var allowedImages []string
+	for _, image := range strings.Split(allowedWorkerImages, ",") {
+		if image = strings.TrimSpace(image); image != "" {
+			allowedImages = append(allowedImages, image)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "79c6e895.kyma-project.io",
+
+		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
+		// when the Manager ends. This requires the binary to immediately end when the
+		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
+		// speeds up voluntary leader transitions as the new leader don't have to wait
+		// LeaseDuration time first.
+		//
+		// In the default scaffold provided, the program ends immediately after
+		// the manager stops, so would be fine to enable this option. However,
+		// if you are doing or is intended to do any operation such as perform cleanups
+		// after the manager stops then its usage might be unsafe.
+		// LeaderElectionReleaseOnCancel: true,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	reconciler := &controller.ClusterIPReconciler{
+		Client:              mgr.GetClient(),
+		APIReader:           mgr.GetAPIReader(),
+		Scheme:              mgr.GetScheme(),
+		SystemNamespace:     systemNamespace,
+		WorkerImage:         workerImage,
+		AllowedWorkerImages: allowedImages,
+		Notifier:            notification.NewSender(10 * time.Second),
+		Recorder:            mgr.GetEventRecorderFor("cluster-ip"),
+		GeoIP:               databases,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterIP")
+		os.Exit(1)
+	}
+	if err = (&controller.GlobalClusterIPReconciler{ClusterIPReconciler: reconciler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GlobalClusterIP")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&operatorv1beta1.ClusterIPWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterIP")
+			os.Exit(1)
+		}
+	}
+	//+kubebuilder:scaffold:builder
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	setupLog.Info("starting manager")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running manager")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLegacyWorkerArgs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "namespace")
+	if err := os.WriteFile(file, []byte("kyma-system\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous string) { serviceAccountNamespaceFile = previous }(serviceAccountNamespaceFile)
+	serviceAccountNamespaceFile = file
+
+	tests := []struct {
+		name            string
+		systemNamespace string
+		expected        string
+	}{
+		{name: "flag", systemNamespace: "operator", expected: "operator"},
+		{name: "pod namespace", expected: "kyma-system"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := legacyWorkerArgs("zone-a", "topology.kubernetes.io/zone", tt.systemNamespace)
+			i := slices.Index(args, "--system-namespace")
+			if i < 0 || i+1 >= len(args) || args[i+1] != tt.expected {
+				t.Errorf("expected --system-namespace %s, got %v", tt.expected, args)
+			}
+		})
+	}
+}
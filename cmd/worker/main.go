@@ -0,0 +1,13 @@
+// worker determines the egress IP of a node label once, reports it in the ClusterIP status and exits.
+// It is started by the cluster-ip manager in a pod scheduled to the node label.
+package main
+
+import (
+	"os"
+
+	"github.com/kyma-project/cluster-ip/internal/worker"
+)
+
+func main() {
+	os.Exit(worker.Main(os.Args[1:]))
+}
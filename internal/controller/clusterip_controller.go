@@ -18,16 +18,22 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	"hash/crc32"
 	s "strings"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,65 +42,123 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	"github.com/kyma-project/cluster-ip/api/v1alpha1"
-	operatorv1alpha1 "github.com/kyma-project/cluster-ip/api/v1alpha1"
-	"github.com/kyma-project/cluster-ip/internal/ip"
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/geoip"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+	"github.com/kyma-project/cluster-ip/internal/status"
+	"github.com/kyma-project/cluster-ip/internal/worker"
+)
+
+const ConditionWorkerImage = "WorkerImageResolved"
+
+const (
+	// workerRetry is how long the manager waits before it tries to create or delete a worker pod again.
+	workerRetry = 5 * time.Second
+	// workerFailureBackoff is how long a failed worker pod is kept before the worker is started again.
+	workerFailureBackoff = time.Minute
+	// workerStartDeadline is how long a worker pod may be pending, for example because its image can't be pulled
+	// or it can't be scheduled, before it is replaced.
+	workerStartDeadline = 5 * time.Minute
+
+	zoneLabel = "cluster-ip.operator.kyma-project.io/zone"
+	// ownerUIDLabel holds the UID of the ClusterIP a worker pod reports to, so that ClusterIPs using the same
+	// node label values don't take over each other's workers.
+	ownerUIDLabel = "cluster-ip.operator.kyma-project.io/owner-uid"
+	// ownerAnnotation holds namespace/name of the ClusterIP a worker pod reports to, so that pod events reconcile it.
+	ownerAnnotation = "cluster-ip.operator.kyma-project.io/owner"
 )
 
 // ClusterIPReconciler reconciles a ClusterIP object
 type ClusterIPReconciler struct {
 	client.Client
+	// APIReader reads objects which should not be cached by the manager, like secrets.
+	APIReader       client.Reader
 	Scheme          *runtime.Scheme
-	Node            string
-	NodeSpreadLabel string
 	SystemNamespace string
-	NodeIP          map[string]string
-	StartTime       metav1.Time
+	Notifier        *notification.Sender
+	// WorkerImage is the image of the worker pods unless the ClusterIP spec overrides it.
+	WorkerImage string
+	// AllowedWorkerImages are the image repositories the ClusterIP spec may set as worker image.
+	// Without them the worker image of the spec is rejected.
+	AllowedWorkerImages []string
+	// Recorder emits events about discovered, changed and failed node IPs, which make up the IP history.
+	Recorder record.EventRecorder
+	// GeoIP are the local databases the location and network of the node IPs are looked up in.
+	GeoIP geoip.Databases
 }
 
-func (r *ClusterIPReconciler) MyImageName(ctx context.Context) string {
-	logger := log.FromContext(ctx)
-	imageName := os.Getenv("IMAGE_NAME")
+// errWorkerImageNotAllowed is returned for a worker image of the spec which isn't in AllowedWorkerImages.
+var errWorkerImageNotAllowed = errors.New("worker image is not allowed")
+
+// WorkerImageName returns the image of the worker pods. The image set in the spec takes precedence over
+// the --worker-image flag, if its repository is allowed with --allowed-worker-images. If neither is set,
+// the image of the manager container is read from the own pod found with the downward API environment variables.
+func (r *ClusterIPReconciler) WorkerImageName(ctx context.Context, spec *v1beta1.ClusterIPSpec) (string, error) {
+	if spec.WorkerImage != "" {
+		if !slices.Contains(r.AllowedWorkerImages, imageRepository(spec.WorkerImage)) {
+			return "", fmt.Errorf("%w: %s isn't in the repositories of --allowed-worker-images", errWorkerImageNotAllowed, spec.WorkerImage)
+		}
+		return spec.WorkerImage, nil
+	}
+	if r.WorkerImage != "" {
+		return r.WorkerImage, nil
+	}
+	ns := os.Getenv("MY_POD_NAMESPACE")
+	name := os.Getenv("MY_POD_NAME")
+	if ns == "" || name == "" {
+		return "", fmt.Errorf("can't find the worker image: set --worker-image or the MY_POD_NAME and MY_POD_NAMESPACE environment variables")
+	}
 	var pod corev1.Pod
-	if imageName == "" {
-		ns := os.Getenv("MY_POD_NAMESPACE")
-		name := os.Getenv("MY_POD_NAME")
-		err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, &pod)
-		if err != nil {
-			logger.Error(err, "Can't find my pod", "ns", ns, "pod", name)
-			panic(err)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, &pod); err != nil {
+		return "", fmt.Errorf("can't find the worker image: can't get pod %s/%s: %w", ns, name, err)
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "manager" {
+			return c.Image, nil
 		}
-		for _, c := range pod.Spec.Containers {
-			if s.Contains(c.Image, "cluster-ip") {
-				imageName = c.Image
-				logger.Info("Found my container", "image", c.Image)
-				break
-			}
+	}
+	for _, c := range pod.Spec.Containers {
+		if s.Contains(c.Image, "cluster-ip") {
+			return c.Image, nil
 		}
 	}
-	if imageName == "" {
-		err := fmt.Errorf("cannot find controller image")
-		panic(err)
+	return "", fmt.Errorf("can't find the worker image: pod %s/%s has no manager container", ns, name)
+}
+
+// imageRepository returns the image without tag and digest.
+func imageRepository(image string) string {
+	image, _, _ = s.Cut(image, "@")
+	if i := s.LastIndex(image, ":"); i > s.LastIndex(image, "/") {
+		image = image[:i]
 	}
-	return imageName
+	return image
 }
+
 func hash(input string) string {
 	crc32q := crc32.MakeTable(0xD5828281)
 	return fmt.Sprintf("%08x", crc32.Checksum([]byte(input), crc32q))
 }
-func (r *ClusterIPReconciler) FindZonedPod(ctx context.Context, zone string) *corev1.Pod {
+
+// FindZonedPod returns the worker pod of the ClusterIP for the node label. Pods created by older versions without
+// owner UID are adopted if they report to the ClusterIP or to all of them, so that they are replaced.
+func (r *ClusterIPReconciler) FindZonedPod(ctx context.Context, clusterIP v1beta1.ClusterIPObject, zone string) *corev1.Pod {
 	var pods corev1.PodList
 	logger := log.FromContext(ctx)
-	err := r.List(ctx, &pods, client.InNamespace(r.SystemNamespace), client.MatchingLabels{"cluster-ip.operator.kyma-project.io/zone": hash(zone)})
+	err := r.List(ctx, &pods, client.InNamespace(r.SystemNamespace), client.MatchingLabels{zoneLabel: hash(zone)})
 	if err != nil {
 		logger.Error(err, "Can't fetch pods", "err", err)
 		return nil
 	}
-	if pods.Items != nil && len(pods.Items) > 0 {
-		return &pods.Items[0]
+	owner := clusterIP.GetNamespace() + "/" + clusterIP.GetName()
+	for i, pod := range pods.Items {
+		uid, ok := pod.Labels[ownerUIDLabel]
+		if uid == string(clusterIP.GetUID()) || !ok && (pod.Annotations[ownerAnnotation] == owner || pod.Annotations[ownerAnnotation] == "") {
+			return &pods.Items[i]
+		}
 	}
 	return nil
 }
+
 func (r *ClusterIPReconciler) GetNodeLabels(ctx context.Context, nodeSpreadLabel string) []string {
 	logger := log.FromContext(ctx)
 	var nodes corev1.NodeList
@@ -121,101 +185,227 @@ func (r *ClusterIPReconciler) GetNodeLabels(ctx context.Context, nodeSpreadLabel
 	return result
 }
 
-func (r *ClusterIPReconciler) CreateOrUpdatePod(ctx context.Context, label string, nodeSpreadLabel string, image string) *corev1.Pod {
-	existingPod := r.FindZonedPod(ctx, label)
-	podTemplate := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
-		Name:      "cluster-ip-worker-pod-" + hash(label),
-		Namespace: r.SystemNamespace,
-		Labels:    map[string]string{"cluster-ip.operator.kyma-project.io/zone": hash(label)},
-	},
-		Spec: corev1.PodSpec{Containers: []corev1.Container{corev1.Container{
-			Name:  "worker",
-			Image: image,
-			Args:  []string{"--node", label, "--nodeSpreadLabel", nodeSpreadLabel},
-		}},
+// workerPod returns the pod which determines the IP of the node label and reports it to the ClusterIP.
+func (r *ClusterIPReconciler) workerPod(clusterIP v1beta1.ClusterIPObject, label string, image string) *corev1.Pod {
+	nodeSpreadLabel := clusterIP.GetSpec().NodeSpreadLabel
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cluster-ip-worker-pod-" + hash(string(clusterIP.GetUID())) + "-" + hash(label),
+			Namespace:   r.SystemNamespace,
+			Labels:      map[string]string{zoneLabel: hash(label), ownerUIDLabel: string(clusterIP.GetUID())},
+			Annotations: map[string]string{ownerAnnotation: clusterIP.GetNamespace() + "/" + clusterIP.GetName()},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    "worker",
+				Image:   image,
+				Command: []string{"/worker"},
+				Args: []string{"--node", label, "--nodeSpreadLabel", nodeSpreadLabel,
+					"--name", clusterIP.GetName(), "--namespace", clusterIP.GetNamespace(), "--uid", string(clusterIP.GetUID()),
+					"--system-namespace", r.SystemNamespace, "--start-delay", startDelay(clusterIP, label).String()},
+			}},
+			RestartPolicy:      corev1.RestartPolicyNever,
 			NodeSelector:       map[string]string{nodeSpreadLabel: label},
-			ServiceAccountName: "cluster-ip-controller-manager",
-		}}
+			ServiceAccountName: workerServiceAccount,
+		},
+	}
+}
+
+// startDelay spreads the worker starts over the start jitter of the ClusterIP. The delay is derived from the
+// node label, so that the workers of the labels start at different times, but the pod of a label stays up to date.
+func startDelay(clusterIP v1beta1.ClusterIPObject, label string) time.Duration {
+	jitter := clusterIP.GetSpec().WorkerStartJitter
+	if jitter == nil || jitter.Duration <= 0 {
+		return 0
+	}
+	fraction := crc32.ChecksumIEEE([]byte(clusterIP.GetNamespace()+"/"+clusterIP.GetName()+"/"+label)) % 1000
+	return (jitter.Duration * time.Duration(fraction) / 1000).Truncate(time.Millisecond)
+}
+
+// ReconcileWorkerPod starts the worker of the node label and removes it when it completes. A failed worker is
+// started again after workerFailureBackoff, and an outdated one is replaced, because the container of a pod can't be updated.
+// A worker which doesn't start within workerStartDeadline is replaced, and the node IP entry reports why.
+// It returns when the ClusterIP should be reconciled again, or 0 if the pod events trigger the reconciliation.
+func (r *ClusterIPReconciler) ReconcileWorkerPod(ctx context.Context, clusterIP v1beta1.ClusterIPObject, label string, image string, now time.Time) time.Duration {
 	logger := log.FromContext(ctx)
-	if existingPod == nil {
+	desired := r.workerPod(clusterIP, label, image)
+	existing := r.FindZonedPod(ctx, clusterIP, label)
+	if existing == nil {
 		logger.Info("Creating new pod for node label", "label", label)
-		if err := r.Create(ctx, podTemplate); err != nil {
+		if err := r.Create(ctx, desired); err != nil {
 			logger.Error(err, "Can't create pod")
-			return nil
+			return workerRetry
 		}
-		return podTemplate
-	} else {
-		logger.Info("Updating pod for node label", "label", label)
-		for i := range existingPod.Spec.Containers {
-			c := &existingPod.Spec.Containers[i]
-			if c.Name == "worker" {
-				c.Image = image
-				c.Args = []string{"--node", label, "--nodeSpreadLabel", nodeSpreadLabel}
-			}
+		return 0
+	}
+	if existing.DeletionTimestamp != nil {
+		return 0
+	}
+	switch {
+	case !sameWorker(existing, desired):
+		logger.Info("Replacing outdated pod for node label", "label", label)
+	case existing.Status.Phase == corev1.PodSucceeded:
+		logger.Info("Worker completed", "label", label)
+	case existing.Status.Phase == corev1.PodFailed:
+		if retry := finishedAt(existing).Add(workerFailureBackoff).Sub(now); retry > 0 {
+			return retry
+		}
+		logger.Info("Restarting failed worker", "label", label)
+	case existing.Status.Phase == corev1.PodPending:
+		if wait := existing.CreationTimestamp.Add(workerStartDeadline).Sub(now); wait > 0 {
+			return wait
 		}
-		if err := r.Update(ctx, existingPod); err != nil {
-			logger.Error(err, "Can't update pod")
-			return nil
+		message := fmt.Sprintf("worker pod didn't start within %s: %s", workerStartDeadline, pendingReason(existing))
+		logger.Info("Replacing worker pod which didn't start", "label", label, "reason", message)
+		if err := r.setNodeIPError(ctx, clusterIP, label, message); err != nil {
+			logger.Error(err, "Can't update status", "label", label)
 		}
-		return existingPod
+	default:
+		return 0
+	}
+	if err := r.Delete(ctx, existing); err != nil {
+		logger.Error(err, "Can't delete pod")
+		return workerRetry
 	}
+	return 0
 }
-func (r *ClusterIPReconciler) ReconcileWorker(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	var clusterIP v1alpha1.ClusterIP
-	logger := log.FromContext(ctx)
 
-	err := r.Get(ctx, req.NamespacedName, &clusterIP)
-	if err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+// pendingReason returns why the pod doesn't start, like ImagePullBackOff or an unschedulable pod.
+func pendingReason(pod *corev1.Pod) string {
+	for _, c := range pod.Status.ContainerStatuses {
+		if w := c.State.Waiting; w != nil && w.Reason != "" {
+			return s.TrimSuffix(w.Reason+": "+w.Message, ": ")
+		}
 	}
-	if r.NodeSpreadLabel != clusterIP.Spec.NodeSpreadLabel {
-		logger.Info("Skip reconciliation", "node", r.Node, "nodeSpreadLabel", r.NodeSpreadLabel, "clusterIP", clusterIP)
-		return ctrl.Result{}, nil
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			return s.TrimSuffix(c.Reason+": "+c.Message, ": ")
+		}
 	}
+	return "pod is pending"
+}
 
-	ip, err := ip.GetIP(2)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
-	found := false
-	for i, z := range clusterIP.Status.NodeIPs {
-
-		if z.NodeLabel == r.Node {
-			if z.IP == ip && z.LastUpdateTime.After(r.StartTime.Time) {
-				logger.Info("Nothing to do", "zone", z, "ip", ip, "lastUpdate", z.LastUpdateTime, "startTime", r.StartTime)
-				return ctrl.Result{}, nil // nothing to do, everything is up to date
-			} else {
-				node := &clusterIP.Status.NodeIPs[i]
-				node.IP = ip
-				node.LastUpdateTime = metav1.Now()
-				found = true
-				logger.Info("Updating", "node", node)
-			}
-			break
+// setNodeIPError reports the error in the node IP entry of the node label, keeping its addresses.
+// A Warning event is emitted when the error changes, like the workers do for failed discoveries.
+func (r *ClusterIPReconciler) setNodeIPError(ctx context.Context, clusterIP v1beta1.ClusterIPObject, label, message string) error {
+	current := clusterIP.GetStatus()
+	node := v1beta1.NodeIP{NodeLabel: label}
+	i := slices.IndexFunc(current.NodeIPs, func(n v1beta1.NodeIP) bool { return n.NodeLabel == label })
+	if i >= 0 {
+		node = *current.NodeIPs[i].DeepCopy()
+	}
+	if node.Error == message {
+		return nil
+	}
+	node.Error = message
+	// A conflict means a worker wrote the entry meanwhile. The update of the ClusterIP reconciles it again.
+	if err := status.ApplyNodeIPIfUnchanged(ctx, r.Client, clusterIP, node); apierrors.IsConflict(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if i >= 0 {
+		current.NodeIPs[i] = node
+	} else {
+		current.NodeIPs = append(current.NodeIPs, node)
+	}
+	r.event(clusterIP, corev1.EventTypeWarning, "WorkerNotStarted", "%s: %s", label, message)
+	return nil
+}
+
+func sameWorker(existing, desired *corev1.Pod) bool {
+	i := slices.IndexFunc(existing.Spec.Containers, func(c corev1.Container) bool { return c.Name == "worker" })
+	if i < 0 {
+		return false
+	}
+	c, d := existing.Spec.Containers[i], desired.Spec.Containers[0]
+	return c.Image == d.Image && slices.Equal(c.Command, d.Command) && slices.Equal(c.Args, d.Args)
+}
+
+// finishedAt returns when the worker container terminated, or when the pod was created if it is not known.
+func finishedAt(pod *corev1.Pod) time.Time {
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.State.Terminated != nil {
+			return c.State.Terminated.FinishedAt.Time
 		}
 	}
-	if !found {
-		logger.Info("Not found label - creating", "label", r.Node)
+	return pod.CreationTimestamp.Time
+}
 
-		clusterIP.Status.NodeIPs = append(clusterIP.Status.NodeIPs,
-			operatorv1alpha1.NodeIP{
-				NodeLabel:      r.Node,
-				IP:             ip,
-				LastUpdateTime: metav1.Now()})
+// fresh returns if the node IP entry can be used without running the worker again, and until when.
+// The entry must be written for the current generation of the ClusterIP, after the last refresh request
+// and not earlier than the refresh interval ago. The state is persisted, so it survives manager restarts.
+func fresh(clusterIP v1beta1.ClusterIPObject, node v1beta1.NodeIP, now time.Time) (time.Time, bool) {
+	spec, status := clusterIP.GetSpec(), clusterIP.GetStatus()
+	if node.ObservedGeneration != clusterIP.GetGeneration() || node.LastUpdateTime.IsZero() || worker.AddressPolicy(spec).Check(node.PrimaryIP()) != nil {
+		return time.Time{}, false
+	}
+	if status.LastRefreshTime != nil && !node.LastUpdateTime.After(status.LastRefreshTime.Time) {
+		return time.Time{}, false
 	}
-	if clusterIP.Status.State == "" {
-		clusterIP.Status.State = "Processing"
+	if spec.RefreshInterval == nil || spec.RefreshInterval.Duration <= 0 {
+		return time.Time{}, true
 	}
-	logger.Info("Status update started", "status", clusterIP.Status)
-	err = r.Status().Update(ctx, &clusterIP)
+	expiry := node.LastUpdateTime.Add(spec.RefreshInterval.Duration)
+	return expiry, now.Before(expiry)
+}
+
+// handleRefreshRequest records the refresh-requested-at annotation value and time in the status if the annotation changed,
+// so that workers run again for every node label. It returns true if the status changed.
+func (r *ClusterIPReconciler) handleRefreshRequest(ctx context.Context, clusterIP v1beta1.ClusterIPObject) bool {
+	status := clusterIP.GetStatus()
+	requested := clusterIP.GetAnnotations()[v1beta1.RefreshRequestedAnnotation]
+	if requested == "" || requested == status.LastRefreshRequest {
+		return false
+	}
+	log.FromContext(ctx).Info("Refresh requested", "requestedAt", requested)
+	now := metav1.Now()
+	status.LastRefreshRequest = requested
+	status.LastRefreshTime = &now
+	r.event(clusterIP, corev1.EventTypeNormal, "RefreshRequested", "refresh requested at %s", requested)
+	return true
+}
+
+// setWorkerImageCondition reports if the worker image is known. Without it no workers can run,
+// so the state is Error until the image is found. It returns true if the status changed.
+func (r *ClusterIPReconciler) setWorkerImageCondition(clusterIP v1beta1.ClusterIPObject, image string, err error) bool {
+	status := clusterIP.GetStatus()
+	condition := metav1.Condition{
+		Type:               ConditionWorkerImage,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Resolved",
+		Message:            image,
+		ObservedGeneration: clusterIP.GetGeneration(),
+	}
+	changed := false
 	if err != nil {
-		logger.Error(err, "Can't update status", "err", err)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NotFound"
+		if errors.Is(err, errWorkerImageNotAllowed) {
+			condition.Reason = "NotAllowed"
+		}
+		condition.Message = err.Error()
+		if status.State != "Error" || status.Info != err.Error() {
+			status.State = "Error"
+			status.Info = err.Error()
+			changed = true
+		}
+	} else if c := meta.FindStatusCondition(status.Conditions, ConditionWorkerImage); c != nil && c.Status == metav1.ConditionFalse && status.State == "Error" {
+		status.State = "Processing"
+		status.Info = ""
+		changed = true
 	}
+	return meta.SetStatusCondition(&status.Conditions, condition) || changed
+}
 
-	return ctrl.Result{}, nil
+func (r *ClusterIPReconciler) event(clusterIP v1beta1.ClusterIPObject, eventType, reason, messageFmt string, args ...any) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(clusterIP, eventType, reason, messageFmt, args...)
+	}
 }
+
 func (r *ClusterIPReconciler) NodeWatcherToRequests(ctx context.Context, node client.Object) []reconcile.Request {
-	var clusterIPs v1alpha1.ClusterIPList
+	var clusterIPs v1beta1.ClusterIPList
 	err := r.List(ctx, &clusterIPs)
 	if err != nil {
 		return []reconcile.Request{}
@@ -234,11 +424,60 @@ func (r *ClusterIPReconciler) NodeWatcherToRequests(ctx context.Context, node cl
 	return requests
 }
 
+// PodWatcherToRequests maps worker pods to the ClusterIP they report to.
+func (r *ClusterIPReconciler) PodWatcherToRequests(ctx context.Context, pod client.Object) []reconcile.Request {
+	if namespace, name, ok := s.Cut(pod.GetAnnotations()[ownerAnnotation], "/"); ok && namespace != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+	}
+	return nil
+}
+
+// PodWatcherToGlobalRequests maps worker pods to the GlobalClusterIP they report to.
+func (r *ClusterIPReconciler) PodWatcherToGlobalRequests(ctx context.Context, pod client.Object) []reconcile.Request {
+	if namespace, name, ok := s.Cut(pod.GetAnnotations()[ownerAnnotation], "/"); ok && namespace == "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+	}
+	return nil
+}
+
+func (r *ClusterIPReconciler) NodeWatcherToGlobalRequests(ctx context.Context, node client.Object) []reconcile.Request {
+	var clusterIPs v1beta1.GlobalClusterIPList
+	err := r.List(ctx, &clusterIPs)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+	logger := log.FromContext(ctx)
+	logger.Info("NodeWatcher invoked", "node", node.GetName(), "globalClusterIP count", len(clusterIPs.Items))
+	requests := make([]reconcile.Request, len(clusterIPs.Items))
+	for i, item := range clusterIPs.Items {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: item.GetName()},
+		}
+	}
+	return requests
+}
+
+// namespace returns the namespace of objects created for the ClusterIP, which is the system namespace for GlobalClusterIP.
+func (r *ClusterIPReconciler) namespace(clusterIP v1beta1.ClusterIPObject) string {
+	if clusterIP.GetNamespace() == "" {
+		return r.SystemNamespace
+	}
+	return clusterIP.GetNamespace()
+}
+
 //+kubebuilder:rbac:groups=operator.kyma-project.io,resources=clusterips,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=operator.kyma-project.io,resources=clusterips/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=operator.kyma-project.io,resources=clusterips/finalizers,verbs=update
+//+kubebuilder:rbac:groups=operator.kyma-project.io,resources=globalclusterips,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=operator.kyma-project.io,resources=globalclusterips/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=operator.kyma-project.io,resources=globalclusterips/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;delete
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -250,77 +489,138 @@ func (r *ClusterIPReconciler) NodeWatcherToRequests(ctx context.Context, node cl
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
 func (r *ClusterIPReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	if r.Node != "" { // worker pod
-		return r.ReconcileWorker(ctx, req)
-	}
-
-	var clusterIP v1alpha1.ClusterIP
+	return r.reconcile(ctx, req, &v1beta1.ClusterIP{})
+}
 
+func (r *ClusterIPReconciler) reconcile(ctx context.Context, req ctrl.Request, clusterIP v1beta1.ClusterIPObject) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
-	err := r.Get(ctx, req.NamespacedName, &clusterIP)
+	err := r.Get(ctx, req.NamespacedName, clusterIP)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			deleteProviderHealth(req.Namespace, req.Name)
+			deleteCompliance(req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	image := r.MyImageName(ctx)
-	zones := r.GetNodeLabels(ctx, clusterIP.Spec.NodeSpreadLabel)
-	logger.Info("Reconciliation", "cr", clusterIP.Name, "label", clusterIP.Spec.NodeSpreadLabel, "values", zones)
+	spec, status := clusterIP.GetSpec(), clusterIP.GetStatus()
+	image, err := r.WorkerImageName(ctx, spec)
+	if r.setWorkerImageCondition(clusterIP, image, err) {
+		if applyErr := r.ApplyManagerStatus(ctx, clusterIP); applyErr != nil {
+			logger.Error(applyErr, "Can't update status")
+			return ctrl.Result{}, applyErr
+		}
+	}
+	if err != nil {
+		logger.Error(err, "Can't start workers")
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	zones := r.GetNodeLabels(ctx, spec.NodeSpreadLabel)
+	logger.Info("Reconciliation", "cr", clusterIP.GetName(), "label", spec.NodeSpreadLabel, "values", zones)
 	allDone := true
 	updateStatus := false
+	var errs []error
+	if r.handleRefreshRequest(ctx, clusterIP) {
+		updateStatus = true
+	}
+	now := time.Now()
+	recordProviderHealth(clusterIP.GetNamespace(), clusterIP.GetName(), status.NodeIPs, now)
+	if err := r.ReconcileWorkerRBAC(ctx, clusterIP); err != nil {
+		logger.Error(err, "Can't grant workers access to the referenced secrets and config maps")
+		errs = append(errs, err)
+	}
+	if err := r.EnrichNodeIPs(ctx, clusterIP); err != nil {
+		logger.Error(err, "Can't update node IP metadata")
+		errs = append(errs, err)
+	}
+	var requeue time.Duration
 	for _, z := range zones {
-		found := false
-
-		for _, s := range clusterIP.Status.NodeIPs {
-
-			if s.NodeLabel == z {
-				found = true
-				if ip.IsValidIP4(s.IP) && s.LastUpdateTime.After(r.StartTime.Time) {
-					r.NodeIP[z] = s.IP
-					pod := r.FindZonedPod(ctx, z)
-					r.Delete(ctx, pod)
-				} else {
-					allDone = false
+		i := slices.IndexFunc(status.NodeIPs, func(n v1beta1.NodeIP) bool { return n.NodeLabel == z })
+		if i >= 0 {
+			if expiry, ok := fresh(clusterIP, status.NodeIPs[i], now); ok {
+				if pod := r.FindZonedPod(ctx, clusterIP, z); pod != nil && pod.DeletionTimestamp == nil {
+					if err := r.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+						logger.Error(err, "Can't delete pod", "label", z)
+						errs = append(errs, err)
+					}
+				}
+				if !expiry.IsZero() && (requeue == 0 || expiry.Sub(now) < requeue) {
+					requeue = expiry.Sub(now)
 				}
-				break
+				continue
 			}
 		}
-
-		if r.NodeIP[z] == "" {
-			r.CreateOrUpdatePod(ctx, z, clusterIP.Spec.NodeSpreadLabel, image)
+		allDone = false
+		if retry := r.ReconcileWorkerPod(ctx, clusterIP, z, image, now); retry > 0 && (requeue == 0 || retry < requeue) {
+			requeue = retry
 		}
-
-		if !found {
-			if r.NodeIP[z] != "" {
-				clusterIP.Status.NodeIPs = append(clusterIP.Status.NodeIPs, operatorv1alpha1.NodeIP{NodeLabel: z,
-					IP:             r.NodeIP[z],
-					LastUpdateTime: metav1.Now()})
-				updateStatus = true
-			} else {
-				allDone = false
+	}
+	if len(zones) > 0 {
+		for _, n := range status.NodeIPs {
+			if slices.Contains(zones, n.NodeLabel) {
+				continue
+			}
+			logger.Info("Removing node label which is gone", "label", n.NodeLabel)
+			if err := r.RemoveNodeIP(ctx, clusterIP, n.NodeLabel); err != nil {
+				logger.Error(err, "Can't update status", "label", n.NodeLabel)
+				errs = append(errs, err)
 			}
 		}
+		status.NodeIPs = slices.DeleteFunc(status.NodeIPs, func(n v1beta1.NodeIP) bool {
+			return !slices.Contains(zones, n.NodeLabel)
+		})
+	}
+	if status.State == "" {
+		status.State = "Processing"
+		updateStatus = true
+	}
+	if allDone && status.State != "Ready" {
+		status.State = "Ready"
+		updateStatus = true
 	}
-	if clusterIP.Status.State == "" {
-		clusterIP.Status.State = "Processing"
+	if r.ReconcileCompliance(clusterIP) {
 		updateStatus = true
 	}
-	if allDone && clusterIP.Status.State != "Ready" {
-		clusterIP.Status.State = "Ready"
+	if r.ReconcileDNSEndpoint(ctx, clusterIP) {
 		updateStatus = true
 	}
+	notified, retry := r.Notify(ctx, clusterIP)
+	updateStatus = updateStatus || notified
+	if retry > 0 && (requeue == 0 || retry < requeue) {
+		requeue = retry
+	}
 	if updateStatus {
-		err = r.Status().Update(ctx, &clusterIP)
-		if err != nil {
-			logger.Error(err, "Can't update status", "err", err)
+		if err := r.ApplyManagerStatus(ctx, clusterIP); err != nil {
+			logger.Error(err, "Can't update status")
+			errs = append(errs, err)
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeue}, errors.Join(errs...)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterIPReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&operatorv1alpha1.ClusterIP{}).
+		For(&v1beta1.ClusterIP{}).
 		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.NodeWatcherToRequests), builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.PodWatcherToRequests)).
+		Complete(r)
+}
+
+// GlobalClusterIPReconciler reconciles a GlobalClusterIP object the same way as ClusterIP
+type GlobalClusterIPReconciler struct {
+	*ClusterIPReconciler
+}
+
+func (r *GlobalClusterIPReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(ctx, req, &v1beta1.GlobalClusterIP{})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GlobalClusterIPReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.GlobalClusterIP{}).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.NodeWatcherToGlobalRequests), builder.WithPredicates(predicate.ResourceVersionChangedPredicate{})).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.PodWatcherToGlobalRequests)).
 		Complete(r)
 }
@@ -0,0 +1,325 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// newReconciler returns a reconciler with a fake client holding the objects. The fake recorder keeps the emitted events.
+func newReconciler(t *testing.T, objs ...client.Object) (*ClusterIPReconciler, *record.FakeRecorder) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk := range scheme.AllKnownTypes() {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	mapper.Add(DNSEndpointGVK, meta.RESTScopeNamespace)
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(mapper).
+		WithObjects(objs...).
+		WithStatusSubresource(&v1beta1.ClusterIP{}, &v1beta1.GlobalClusterIP{}).
+		Build()
+	recorder := record.NewFakeRecorder(10)
+	return &ClusterIPReconciler{Client: c, Scheme: scheme, SystemNamespace: "kyma-system", Recorder: recorder}, recorder
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) metav1.Time { return metav1.NewTime(now.Add(d)) }
+	refreshedAt := at(-time.Hour)
+	tests := []struct {
+		name       string
+		generation int64
+		spec       v1beta1.ClusterIPSpec
+		status     v1beta1.ClusterIPStatus
+		node       v1beta1.NodeIP
+		fresh      bool
+		expiry     time.Time
+	}{
+		{
+			name:   "within refresh interval",
+			spec:   v1beta1.ClusterIPSpec{RefreshInterval: &metav1.Duration{Duration: 24 * time.Hour}},
+			node:   v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-time.Hour)},
+			fresh:  true,
+			expiry: now.Add(23 * time.Hour),
+		},
+		{
+			name: "refresh interval passed",
+			spec: v1beta1.ClusterIPSpec{RefreshInterval: &metav1.Duration{Duration: time.Hour}},
+			node: v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-2 * time.Hour)},
+		},
+		{
+			name:  "without refresh interval",
+			node:  v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-100 * time.Hour)},
+			fresh: true,
+		},
+		{
+			name:       "older generation",
+			generation: 2,
+			node:       v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-time.Minute)},
+		},
+		{
+			name: "never updated",
+			node: v1beta1.NodeIP{ObservedGeneration: 1},
+		},
+		{
+			name:   "updated before refresh request",
+			status: v1beta1.ClusterIPStatus{LastRefreshTime: &refreshedAt},
+			node:   v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-2 * time.Hour)},
+		},
+		{
+			name:   "updated after refresh request",
+			status: v1beta1.ClusterIPStatus{LastRefreshTime: &refreshedAt},
+			node:   v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-time.Minute)},
+			fresh:  true,
+		},
+		{
+			name: "address rejected by policy",
+			node: v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-time.Minute), Addresses: []v1beta1.Address{v1beta1.NewAddress("10.0.0.1")}},
+		},
+		{
+			name:  "address allowed by policy",
+			spec:  v1beta1.ClusterIPSpec{AllowedAddressClasses: []v1beta1.AddressClass{"Private"}},
+			node:  v1beta1.NodeIP{ObservedGeneration: 1, LastUpdateTime: at(-time.Minute), Addresses: []v1beta1.Address{v1beta1.NewAddress("10.0.0.1")}},
+			fresh: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generation := tt.generation
+			if generation == 0 {
+				generation = 1
+			}
+			if tt.node.Addresses == nil {
+				tt.node.Addresses = []v1beta1.Address{v1beta1.NewAddress("1.2.3.4")}
+			}
+			clusterIP := &v1beta1.ClusterIP{ObjectMeta: metav1.ObjectMeta{Generation: generation}, Spec: tt.spec, Status: tt.status}
+			expiry, ok := fresh(clusterIP, tt.node, now)
+			if ok != tt.fresh {
+				t.Errorf("expected fresh %t, got %t", tt.fresh, ok)
+			}
+			if ok && !expiry.Equal(tt.expiry) {
+				t.Errorf("expected expiry %v, got %v", tt.expiry, expiry)
+			}
+		})
+	}
+}
+
+func TestHandleRefreshRequest(t *testing.T) {
+	clusterIP := &v1beta1.ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"}}
+	r, recorder := newReconciler(t)
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		annotation string
+		handled    bool
+	}{
+		{"no annotation", "", false},
+		{"first request", "2024-01-01T12:00:00Z", true},
+		{"same request again", "2024-01-01T12:00:00Z", false},
+		{"new request", "2024-01-01T13:00:00Z", true},
+		{"annotation removed", "", false},
+	}
+	for _, tt := range tests {
+		clusterIP.Annotations = map[string]string{}
+		if tt.annotation != "" {
+			clusterIP.Annotations[v1beta1.RefreshRequestedAnnotation] = tt.annotation
+		}
+		previous := clusterIP.Status.LastRefreshTime
+		if handled := r.handleRefreshRequest(ctx, clusterIP); handled != tt.handled {
+			t.Fatalf("%s: expected handled %t, got %t", tt.name, tt.handled, handled)
+		}
+		if !tt.handled {
+			if clusterIP.Status.LastRefreshTime != previous {
+				t.Errorf("%s: expected unchanged refresh time", tt.name)
+			}
+			continue
+		}
+		if clusterIP.Status.LastRefreshRequest != tt.annotation || clusterIP.Status.LastRefreshTime == nil {
+			t.Errorf("%s: expected request %s to be recorded, got %+v", tt.name, tt.annotation, clusterIP.Status)
+		}
+		if event := <-recorder.Events; !strings.Contains(event, "RefreshRequested") {
+			t.Errorf("%s: unexpected event %q", tt.name, event)
+		}
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected one event per request, got %d more", len(recorder.Events))
+	}
+}
+
+func TestWorkerImageName(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		allowed []string
+		result  string
+		reason  string
+	}{
+		{name: "default", result: "operator:1.0"},
+		{name: "allowed tag", image: "registry.example.com:5000/cluster-ip:2.0", allowed: []string{"registry.example.com:5000/cluster-ip"}, result: "registry.example.com:5000/cluster-ip:2.0"},
+		{name: "allowed digest", image: "registry.example.com/cluster-ip@sha256:0123", allowed: []string{"registry.example.com/cluster-ip"}, result: "registry.example.com/cluster-ip@sha256:0123"},
+		{name: "nothing allowed", image: "evil.example.com/miner:1.0", reason: "NotAllowed"},
+		{name: "other repository", image: "registry.example.com/cluster-ip-evil:1.0", allowed: []string{"registry.example.com/cluster-ip"}, reason: "NotAllowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newReconciler(t)
+			r.WorkerImage = "operator:1.0"
+			r.AllowedWorkerImages = tt.allowed
+			clusterIP := &v1beta1.ClusterIP{Spec: v1beta1.ClusterIPSpec{WorkerImage: tt.image}}
+			image, err := r.WorkerImageName(context.Background(), &clusterIP.Spec)
+			if image != tt.result {
+				t.Errorf("expected image %q, got %q", tt.result, image)
+			}
+			r.setWorkerImageCondition(clusterIP, image, err)
+			c := meta.FindStatusCondition(clusterIP.Status.Conditions, ConditionWorkerImage)
+			if tt.reason == "" && c.Status != metav1.ConditionTrue || tt.reason != "" && c.Reason != tt.reason {
+				t.Errorf("unexpected condition %+v", c)
+			}
+		})
+	}
+}
+
+func TestReconcileWorkerPod(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+		Spec:       v1beta1.ClusterIPSpec{NodeSpreadLabel: "topology.kubernetes.io/zone"},
+		Status:     v1beta1.ClusterIPStatus{NodeIPs: []v1beta1.NodeIP{{NodeLabel: "a", Addresses: []v1beta1.Address{v1beta1.NewAddress("1.2.3.4")}}}},
+	}
+	tests := []struct {
+		name    string
+		image   string
+		created time.Duration
+		status  corev1.PodStatus
+		deleted bool
+		retry   time.Duration
+		error   string
+	}{
+		{name: "running", status: corev1.PodStatus{Phase: corev1.PodRunning}},
+		{name: "succeeded", status: corev1.PodStatus{Phase: corev1.PodSucceeded}, deleted: true},
+		{
+			name: "failed within backoff",
+			status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: []corev1.ContainerStatus{{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(now.Add(-20 * time.Second))}},
+			}}},
+			retry: 40 * time.Second,
+		},
+		{name: "failed after backoff", created: -2 * time.Minute, status: corev1.PodStatus{Phase: corev1.PodFailed}, deleted: true},
+		{name: "outdated", image: "worker:0.9", status: corev1.PodStatus{Phase: corev1.PodRunning}, deleted: true},
+		{name: "pending", created: -time.Minute, status: corev1.PodStatus{Phase: corev1.PodPending}, retry: 4 * time.Minute},
+		{
+			name:    "image can't be pulled",
+			created: -10 * time.Minute,
+			status: corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{{
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+			}}},
+			deleted: true,
+			error:   "worker pod didn't start within 5m0s: ImagePullBackOff: Back-off pulling image",
+		},
+		{
+			name:    "unschedulable",
+			created: -10 * time.Minute,
+			status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{{
+				Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available",
+			}}},
+			deleted: true,
+			error:   "worker pod didn't start within 5m0s: Unschedulable: 0/3 nodes are available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clusterIP := clusterIP.DeepCopy()
+			r, recorder := newReconciler(t, clusterIP)
+			pod := r.workerPod(clusterIP, "a", "worker:1.0")
+			if tt.image != "" {
+				pod.Spec.Containers[0].Image = tt.image
+			}
+			pod.CreationTimestamp = metav1.NewTime(now.Add(tt.created))
+			pod.Status = tt.status
+			if err := r.Create(ctx, pod); err != nil {
+				t.Fatal(err)
+			}
+			if retry := r.ReconcileWorkerPod(ctx, clusterIP, "a", "worker:1.0", now); retry != tt.retry {
+				t.Errorf("expected retry after %s, got %s", tt.retry, retry)
+			}
+			err := r.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.deleted {
+				t.Errorf("expected deleted %v, got %v", tt.deleted, err)
+			}
+			var stored v1beta1.ClusterIP
+			if err := r.Get(ctx, client.ObjectKeyFromObject(clusterIP), &stored); err != nil {
+				t.Fatal(err)
+			}
+			node := stored.Status.NodeIPs[0]
+			if node.Error != tt.error || node.PrimaryIP() != "1.2.3.4" {
+				t.Errorf("expected error %q and the addresses kept, got %+v", tt.error, node)
+			}
+			if tt.error != "" && !strings.Contains(<-recorder.Events, "WorkerNotStarted") {
+				t.Error("expected a WorkerNotStarted event")
+			}
+		})
+	}
+}
+
+func TestWorkerPodsOfClusterIPsSharingNodeLabels(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	spec := v1beta1.ClusterIPSpec{NodeSpreadLabel: "topology.kubernetes.io/zone"}
+	clusterIP := &v1beta1.ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "zones", Namespace: "kyma-system", UID: "uid-1"}, Spec: spec}
+	global := &v1beta1.GlobalClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "zones", UID: "uid-2"}, Spec: spec}
+	// a pod of an older version, which reports to the ClusterIP but has no owner UID
+	legacy := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "cluster-ip-worker-pod-" + hash("a"), Namespace: "kyma-system",
+		Labels:      map[string]string{zoneLabel: hash("a")},
+		Annotations: map[string]string{ownerAnnotation: "kyma-system/zones"},
+	}}
+	r, _ := newReconciler(t, clusterIP, global, legacy)
+	if pod := r.FindZonedPod(ctx, clusterIP, "a"); pod == nil || pod.Name != legacy.Name {
+		t.Fatalf("expected the legacy pod to be adopted, got %+v", pod)
+	}
+	if pod := r.FindZonedPod(ctx, global, "a"); pod != nil {
+		t.Fatalf("expected the legacy pod of the ClusterIP not to be adopted by the GlobalClusterIP, got %s", pod.Name)
+	}
+	// the outdated legacy pod is replaced, and the pod of the other resource is left alone
+	for i := 0; i < 3; i++ {
+		for _, obj := range []v1beta1.ClusterIPObject{clusterIP, global} {
+			r.ReconcileWorkerPod(ctx, obj, "a", "worker:1.0", now)
+		}
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 2 {
+		t.Fatalf("expected a pod per resource, got %d", len(pods.Items))
+	}
+	for _, obj := range []v1beta1.ClusterIPObject{clusterIP, global} {
+		pod := r.FindZonedPod(ctx, obj, "a")
+		if pod == nil || pod.Labels[ownerUIDLabel] != string(obj.GetUID()) || !sameWorker(pod, r.workerPod(obj, "a", "worker:1.0")) {
+			t.Errorf("expected the worker of %s, got %+v", obj.GetUID(), pod)
+		}
+	}
+}
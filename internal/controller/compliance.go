@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+const ConditionCompliant = "Compliant"
+
+// ReconcileCompliance checks that the node IPs are within the expected CIDRs of the spec, which catches routing
+// misconfigurations bypassing the NAT gateways. A Warning event is emitted when node IPs outside of them are found.
+// The condition is Unknown until the first node IP is determined. It returns true if the ClusterIP conditions changed.
+func (r *ClusterIPReconciler) ReconcileCompliance(clusterIP v1beta1.ClusterIPObject) bool {
+	spec, status := clusterIP.GetSpec(), clusterIP.GetStatus()
+	deleteCompliance(clusterIP.GetNamespace(), clusterIP.GetName())
+	if len(spec.ExpectedCIDRs) == 0 {
+		return meta.RemoveStatusCondition(&status.Conditions, ConditionCompliant)
+	}
+	if !slices.ContainsFunc(status.NodeIPs, func(n v1beta1.NodeIP) bool { return len(n.Addresses) > 0 }) {
+		return meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionCompliant,
+			Status:             metav1.ConditionUnknown,
+			Reason:             "NoIPs",
+			Message:            "no node IPs are determined yet",
+			ObservedGeneration: clusterIP.GetGeneration(),
+		})
+	}
+	var expected []netip.Prefix
+	for _, cidr := range spec.ExpectedCIDRs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			expected = append(expected, prefix.Masked())
+		}
+	}
+	var violations []string
+	for _, n := range status.NodeIPs {
+		if len(n.Addresses) == 0 {
+			continue
+		}
+		compliant := true
+		for _, ip := range n.IPs() {
+			addr, err := netip.ParseAddr(ip)
+			if err == nil && slices.ContainsFunc(expected, func(p netip.Prefix) bool { return p.Contains(addr.Unmap()) }) {
+				continue
+			}
+			compliant = false
+			violations = append(violations, fmt.Sprintf("%s: %s", n.NodeLabel, ip))
+		}
+		recordCompliance(clusterIP.GetNamespace(), clusterIP.GetName(), n.NodeLabel, compliant)
+	}
+	condition := metav1.Condition{
+		Type:               ConditionCompliant,
+		Status:             metav1.ConditionTrue,
+		Reason:             "WithinExpectedCIDRs",
+		Message:            "all node IPs are within the expected CIDRs",
+		ObservedGeneration: clusterIP.GetGeneration(),
+	}
+	if len(violations) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "UnexpectedEgressIP"
+		condition.Message = "node IPs outside of the expected CIDRs " + strings.Join(spec.ExpectedCIDRs, ", ") + ": " + strings.Join(violations, ", ")
+		if previous := meta.FindStatusCondition(status.Conditions, ConditionCompliant); previous == nil || previous.Message != condition.Message {
+			r.event(clusterIP, corev1.EventTypeWarning, condition.Reason, condition.Message)
+		}
+	}
+	return meta.SetStatusCondition(&status.Conditions, condition)
+}
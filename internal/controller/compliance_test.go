@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+func TestReconcileCompliance(t *testing.T) {
+	node := func(label string, ips ...string) v1beta1.NodeIP {
+		n := v1beta1.NodeIP{NodeLabel: label}
+		for _, ip := range ips {
+			n.Addresses = append(n.Addresses, v1beta1.NewAddress(ip))
+		}
+		return n
+	}
+	tests := []struct {
+		name    string
+		nodeIPs []v1beta1.NodeIP
+		status  metav1.ConditionStatus
+		reason  string
+		warning bool
+	}{
+		{name: "compliant", nodeIPs: []v1beta1.NodeIP{node("a", "1.2.3.4"), node("b", "1.2.3.5")}, status: metav1.ConditionTrue, reason: "WithinExpectedCIDRs"},
+		{name: "not compliant", nodeIPs: []v1beta1.NodeIP{node("a", "1.2.3.4"), node("b", "5.6.7.8")}, status: metav1.ConditionFalse, reason: "UnexpectedEgressIP", warning: true},
+		{name: "no node IPs", status: metav1.ConditionUnknown, reason: "NoIPs"},
+		{name: "no addresses yet", nodeIPs: []v1beta1.NodeIP{{NodeLabel: "a", Error: "timeout"}}, status: metav1.ConditionUnknown, reason: "NoIPs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, recorder := newReconciler(t)
+			clusterIP := &v1beta1.ClusterIP{
+				ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+				Spec:       v1beta1.ClusterIPSpec{ExpectedCIDRs: []string{"1.2.3.0/24"}},
+				Status:     v1beta1.ClusterIPStatus{NodeIPs: tt.nodeIPs},
+			}
+			if !r.ReconcileCompliance(clusterIP) {
+				t.Error("expected the conditions to change")
+			}
+			c := meta.FindStatusCondition(clusterIP.Status.Conditions, ConditionCompliant)
+			if c == nil || c.Status != tt.status || c.Reason != tt.reason {
+				t.Fatalf("expected %s condition with reason %s, got %+v", tt.status, tt.reason, c)
+			}
+			if tt.warning {
+				if event := <-recorder.Events; !strings.HasPrefix(event, "Warning UnexpectedEgressIP") || !strings.Contains(event, "b: 5.6.7.8") {
+					t.Errorf("unexpected event %q", event)
+				}
+			}
+			if len(recorder.Events) > 0 {
+				t.Errorf("unexpected event %q", <-recorder.Events)
+			}
+			// the event is emitted only when the violations change
+			if r.ReconcileCompliance(clusterIP) || len(recorder.Events) > 0 {
+				t.Error("expected no change when reconciled again")
+			}
+		})
+	}
+}
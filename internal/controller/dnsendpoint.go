@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+)
+
+const ConditionDNSEndpointReady = "DNSEndpointReady"
+
+// errDNSEndpointConflict is returned for a DNSEndpoint with the name of the ClusterIP which it doesn't control.
+var errDNSEndpointConflict = errors.New("is not controlled by this resource")
+
+// DNSEndpointGVK is the external-dns kind handled as unstructured object so that its CRD is optional.
+var DNSEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+func dnsEndpoints(spec *v1beta1.DNSEndpoint, ips []string) []any {
+	var v4, v6 []any
+	for _, s := range ips {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			continue
+		}
+		if addr.Is4() {
+			v4 = append(v4, s)
+		} else {
+			v6 = append(v6, s)
+		}
+	}
+	var endpoints []any
+	for _, records := range []struct {
+		recordType string
+		targets    []any
+	}{{"A", v4}, {"AAAA", v6}} {
+		if len(records.targets) > 0 {
+			endpoints = append(endpoints, map[string]any{
+				"dnsName":    spec.DNSName,
+				"recordType": records.recordType,
+				"recordTTL":  spec.TTL,
+				"targets":    records.targets,
+			})
+		}
+	}
+	return endpoints
+}
+
+// dnsEndpointName returns the name of the DNSEndpoint of the ClusterIP. The DNSEndpoint of a GlobalClusterIP is
+// placed in the operator namespace, so its name is prefixed to not clash with a ClusterIP there.
+func dnsEndpointName(clusterIP v1beta1.ClusterIPObject) string {
+	if clusterIP.GetNamespace() == "" {
+		return "global-" + clusterIP.GetName()
+	}
+	return clusterIP.GetName()
+}
+
+// deleteDNSEndpoint deletes the DNSEndpoint if the ClusterIP controls it. Objects of others are left alone.
+func (r *ClusterIPReconciler) deleteDNSEndpoint(ctx context.Context, clusterIP v1beta1.ClusterIPObject, name string) error {
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(DNSEndpointGVK)
+	err := r.Get(ctx, types.NamespacedName{Namespace: r.namespace(clusterIP), Name: name}, endpoint)
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(endpoint, clusterIP) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, endpoint))
+}
+
+// ReconcileDNSEndpoint creates, updates or deletes the DNSEndpoint of the ClusterIP. A DNSEndpoint controlled by
+// another object is never changed, and the condition reason is Conflict.
+// The DNSEndpoint is deleted while there are no node IPs, so that no outdated records are published.
+// It returns true if the ClusterIP conditions changed.
+func (r *ClusterIPReconciler) ReconcileDNSEndpoint(ctx context.Context, clusterIP v1beta1.ClusterIPObject) bool {
+	logger := log.FromContext(ctx)
+	spec, status := clusterIP.GetSpec(), clusterIP.GetStatus()
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(DNSEndpointGVK)
+	endpoint.SetName(dnsEndpointName(clusterIP))
+	endpoint.SetNamespace(r.namespace(clusterIP))
+	if clusterIP.GetNamespace() == "" {
+		// older versions named the DNSEndpoint of a GlobalClusterIP like the resource
+		if err := r.deleteDNSEndpoint(ctx, clusterIP, clusterIP.GetName()); err != nil {
+			logger.Error(err, "Can't delete DNSEndpoint of an older version")
+		}
+	}
+
+	if spec.DNSEndpoint == nil {
+		if meta.FindStatusCondition(status.Conditions, ConditionDNSEndpointReady) == nil {
+			return false
+		}
+		if err := r.deleteDNSEndpoint(ctx, clusterIP, endpoint.GetName()); err != nil {
+			logger.Error(err, "Can't delete DNSEndpoint")
+			return false
+		}
+		return meta.RemoveStatusCondition(&status.Conditions, ConditionDNSEndpointReady)
+	}
+
+	ips := notification.IPs(status.NodeIPs)
+	if len(ips) == 0 {
+		// The old records would keep publishing IPs which aren't the egress anymore.
+		if err := r.deleteDNSEndpoint(ctx, clusterIP, endpoint.GetName()); err != nil {
+			logger.Error(err, "Can't delete DNSEndpoint")
+		}
+		return meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionDNSEndpointReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "NoIPs",
+			Message:            "no node IPs determined yet",
+			ObservedGeneration: clusterIP.GetGeneration(),
+		})
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, endpoint, func() error {
+		if endpoint.GetResourceVersion() != "" && !metav1.IsControlledBy(endpoint, clusterIP) {
+			return errDNSEndpointConflict
+		}
+		if err := unstructured.SetNestedSlice(endpoint.Object, dnsEndpoints(spec.DNSEndpoint, ips), "spec", "endpoints"); err != nil {
+			return err
+		}
+		return controllerutil.SetControllerReference(clusterIP, endpoint, r.Scheme)
+	})
+	condition := metav1.Condition{
+		Type:               ConditionDNSEndpointReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Published",
+		Message:            spec.DNSEndpoint.DNSName,
+		ObservedGeneration: clusterIP.GetGeneration(),
+	}
+	if meta.IsNoMatchError(err) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "CRDNotInstalled"
+		condition.Message = "external-dns DNSEndpoint CRD is not installed"
+	} else if errors.Is(err, errDNSEndpointConflict) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Conflict"
+		condition.Message = fmt.Sprintf("DNSEndpoint %s/%s %s", endpoint.GetNamespace(), endpoint.GetName(), err)
+	} else if err != nil {
+		logger.Error(err, "Can't create or update DNSEndpoint")
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Error"
+		condition.Message = err.Error()
+	}
+	return meta.SetStatusCondition(&status.Conditions, condition)
+}
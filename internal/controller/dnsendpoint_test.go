@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+func TestReconcileDNSEndpoint(t *testing.T) {
+	ctx := context.Background()
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default", UID: "uid"},
+		Spec:       v1beta1.ClusterIPSpec{DNSEndpoint: &v1beta1.DNSEndpoint{DNSName: "egress.example.com", TTL: 60}},
+	}
+	r, _ := newReconciler(t, clusterIP)
+	targets := func() ([]any, error) {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(DNSEndpointGVK)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "egress"}, endpoint); err != nil {
+			return nil, err
+		}
+		endpoints, _, _ := unstructured.NestedSlice(endpoint.Object, "spec", "endpoints")
+		var result []any
+		for _, e := range endpoints {
+			result = append(result, e.(map[string]any)["targets"].([]any)...)
+		}
+		return result, nil
+	}
+	setIPs := func(ips ...string) {
+		clusterIP.Status.NodeIPs = nil
+		for i, ip := range ips {
+			clusterIP.Status.NodeIPs = append(clusterIP.Status.NodeIPs, v1beta1.NodeIP{NodeLabel: string(rune('a' + i)), Addresses: []v1beta1.Address{v1beta1.NewAddress(ip)}})
+		}
+	}
+
+	setIPs("1.2.3.4", "2001:db8::1")
+	r.ReconcileDNSEndpoint(ctx, clusterIP)
+	if got, err := targets(); err != nil || len(got) != 2 || got[0] != "1.2.3.4" || got[1] != "2001:db8::1" {
+		t.Fatalf("unexpected targets after create %v, %v", got, err)
+	}
+	if !meta.IsStatusConditionTrue(clusterIP.Status.Conditions, ConditionDNSEndpointReady) {
+		t.Errorf("expected ready condition, got %+v", clusterIP.Status.Conditions)
+	}
+
+	setIPs("5.6.7.8")
+	r.ReconcileDNSEndpoint(ctx, clusterIP)
+	if got, err := targets(); err != nil || len(got) != 1 || got[0] != "5.6.7.8" {
+		t.Fatalf("unexpected targets after update %v, %v", got, err)
+	}
+
+	setIPs()
+	r.ReconcileDNSEndpoint(ctx, clusterIP)
+	if _, err := targets(); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the DNSEndpoint to be deleted, got %v", err)
+	}
+	if c := meta.FindStatusCondition(clusterIP.Status.Conditions, ConditionDNSEndpointReady); c == nil || c.Status != metav1.ConditionFalse || c.Reason != "NoIPs" {
+		t.Errorf("expected NoIPs condition, got %+v", c)
+	}
+}
+
+func TestReconcileDNSEndpointSameName(t *testing.T) {
+	ctx := context.Background()
+	spec := v1beta1.ClusterIPSpec{DNSEndpoint: &v1beta1.DNSEndpoint{DNSName: "egress.example.com", TTL: 60}}
+	clusterIP := &v1beta1.ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "kyma-system", UID: "uid-1"}, Spec: spec}
+	global := &v1beta1.GlobalClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "egress", UID: "uid-2"}, Spec: spec}
+	for i, obj := range []v1beta1.ClusterIPObject{clusterIP, global} {
+		obj.GetStatus().NodeIPs = []v1beta1.NodeIP{{NodeLabel: "a", Addresses: []v1beta1.Address{v1beta1.NewAddress(fmt.Sprintf("1.2.3.%d", i))}}}
+	}
+	// the DNSEndpoint of the GlobalClusterIP created by an older version, and one of someone else
+	legacy := &unstructured.Unstructured{}
+	legacy.SetGroupVersionKind(DNSEndpointGVK)
+	legacy.SetNamespace("kyma-system")
+	legacy.SetName("egress")
+	legacy.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: v1beta1.GroupVersion.String(), Kind: "GlobalClusterIP", Name: "egress", UID: "uid-2", Controller: ptr.To(true)}})
+	other := &unstructured.Unstructured{}
+	other.SetGroupVersionKind(DNSEndpointGVK)
+	other.SetNamespace("default")
+	other.SetName("egress")
+	r, _ := newReconciler(t, clusterIP, global, legacy, other)
+
+	r.ReconcileDNSEndpoint(ctx, global)
+	if !meta.IsStatusConditionTrue(global.Status.Conditions, ConditionDNSEndpointReady) {
+		t.Fatalf("expected ready condition, got %+v", global.Status.Conditions)
+	}
+	// the legacy DNSEndpoint is replaced, which makes room for the ClusterIP
+	r.ReconcileDNSEndpoint(ctx, clusterIP)
+	if !meta.IsStatusConditionTrue(clusterIP.Status.Conditions, ConditionDNSEndpointReady) {
+		t.Fatalf("expected ready condition, got %+v", clusterIP.Status.Conditions)
+	}
+	for name, uid := range map[string]types.UID{"egress": "uid-1", "global-egress": "uid-2"} {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(DNSEndpointGVK)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: "kyma-system", Name: name}, endpoint); err != nil {
+			t.Fatal(err)
+		}
+		if owner := metav1.GetControllerOf(endpoint); owner == nil || owner.UID != uid {
+			t.Errorf("expected %s to be controlled by %s, got %+v", name, uid, owner)
+		}
+	}
+
+	// a DNSEndpoint not controlled by the ClusterIP is neither updated nor deleted
+	conflicting := &v1beta1.ClusterIP{ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default", UID: "uid-3"}, Spec: spec}
+	conflicting.Status.NodeIPs = clusterIP.Status.NodeIPs
+	r.ReconcileDNSEndpoint(ctx, conflicting)
+	if c := meta.FindStatusCondition(conflicting.Status.Conditions, ConditionDNSEndpointReady); c == nil || c.Reason != "Conflict" {
+		t.Errorf("expected Conflict condition, got %+v", c)
+	}
+	conflicting.Status.NodeIPs = nil
+	r.ReconcileDNSEndpoint(ctx, conflicting)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "egress"}, other); err != nil {
+		t.Errorf("expected the DNSEndpoint of someone else to be kept, got %v", err)
+	}
+	if _, found, _ := unstructured.NestedSlice(other.Object, "spec", "endpoints"); found {
+		t.Errorf("expected the DNSEndpoint of someone else to be unchanged, got %v", other.Object)
+	}
+}
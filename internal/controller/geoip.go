@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/status"
+)
+
+// EnrichNodeIPs sets the metadata of the node IPs from the local GeoIP databases, if any are configured.
+// The databases take precedence over the metadata returned by the providers, so that all node labels are
+// described by the same source.
+func (r *ClusterIPReconciler) EnrichNodeIPs(ctx context.Context, clusterIP v1beta1.ClusterIPObject) error {
+	if len(r.GeoIP) == 0 {
+		return nil
+	}
+	nodeIPs := clusterIP.GetStatus().NodeIPs
+	for i := range nodeIPs {
+		addr, err := netip.ParseAddr(nodeIPs[i].PrimaryIP())
+		if err != nil {
+			continue
+		}
+		record, err := r.GeoIP.Lookup(addr)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "Can't look up IP in the GeoIP databases", "ip", addr)
+			continue
+		}
+		if record.IsZero() {
+			continue
+		}
+		metadata := &v1beta1.IPMetadata{
+			Country: record.Country,
+			City:    record.City,
+			ASN:     int64(record.ASN),
+			Org:     record.Org,
+			Source:  strings.Join(record.Databases, ","),
+		}
+		if nodeIPs[i].Metadata != nil && *nodeIPs[i].Metadata == *metadata {
+			continue
+		}
+		node := *nodeIPs[i].DeepCopy()
+		node.Metadata = metadata
+		// A conflict means a worker wrote an entry meanwhile. The update of the ClusterIP reconciles it again.
+		if err := status.ApplyNodeIPIfUnchanged(ctx, r.Client, clusterIP, node); apierrors.IsConflict(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		nodeIPs[i] = node
+	}
+	return nil
+}
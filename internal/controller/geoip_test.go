@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/geoip"
+)
+
+func TestEnrichNodeIPs(t *testing.T) {
+	ctx := context.Background()
+	databases, err := geoip.OpenAll([]string{"../geoip/testdata/GeoLite2-City-Test.mmdb", "../geoip/testdata/GeoLite2-ASN-Test.mmdb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer databases.Close()
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+		Status: v1beta1.ClusterIPStatus{NodeIPs: []v1beta1.NodeIP{
+			{NodeLabel: "a", Addresses: []v1beta1.Address{v1beta1.NewAddress("81.2.69.142")}, Metadata: &v1beta1.IPMetadata{Country: "US", Source: "ipinfo.io"}},
+			{NodeLabel: "b", Addresses: []v1beta1.Address{v1beta1.NewAddress("1.128.0.1")}},
+			{NodeLabel: "c", Addresses: []v1beta1.Address{v1beta1.NewAddress("198.51.100.1")}},
+			{NodeLabel: "d", Error: "timeout"},
+		}},
+	}
+	r, _ := newReconciler(t, clusterIP)
+	r.GeoIP = databases
+	if err := r.Get(ctx, client.ObjectKeyFromObject(clusterIP), clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.EnrichNodeIPs(ctx, clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	// The fake client doesn't merge the entries applied by the field owners of the node labels,
+	// see internal/status for that, so the entries updated in the ClusterIP are checked.
+	expected := []*v1beta1.IPMetadata{
+		{Country: "GB", City: "London", Source: "GeoLite2-City"},
+		{ASN: 1221, Org: "Telstra Pty Ltd", Source: "GeoLite2-ASN"},
+		nil,
+		nil,
+	}
+	for i, m := range expected {
+		got := clusterIP.Status.NodeIPs[i].Metadata
+		if (m == nil) != (got == nil) || m != nil && *m != *got {
+			t.Errorf("%s: expected metadata %+v, got %+v", clusterIP.Status.NodeIPs[i].NodeLabel, m, got)
+		}
+	}
+}
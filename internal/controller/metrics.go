@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/worker"
+)
+
+var providerLabels = []string{"namespace", "name", "node_label", "provider"}
+
+var (
+	providerHealthScore = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ip_provider_health_score",
+		Help: "Health score between 0 and 1 of an IP provider, from its success rate and consecutive failures.",
+	}, providerLabels)
+	providerLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ip_provider_latency_seconds",
+		Help: "Moving average of the response time of an IP provider.",
+	}, providerLabels)
+	providerConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ip_provider_consecutive_failures",
+		Help: "Number of consecutive failed requests to an IP provider.",
+	}, providerLabels)
+	providerCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ip_provider_circuit_open",
+		Help: "1 if an IP provider is skipped after repeated failures, 0 otherwise.",
+	}, providerLabels)
+
+	providerGauges = []*prometheus.GaugeVec{providerHealthScore, providerLatency, providerConsecutiveFailures, providerCircuitOpen}
+
+	nodeIPCompliant = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cluster_ip_node_ip_compliant",
+		Help: "1 if the egress IPs of a node label are within the expected CIDRs, 0 otherwise.",
+	}, []string{"namespace", "name", "node_label"})
+)
+
+func init() {
+	for _, g := range providerGauges {
+		metrics.Registry.MustRegister(g)
+	}
+	metrics.Registry.MustRegister(nodeIPCompliant)
+}
+
+// recordProviderHealth exports the provider health the workers recorded in the node IPs of the ClusterIP.
+// The namespace is empty for GlobalClusterIP.
+func recordProviderHealth(namespace, name string, nodeIPs []v1beta1.NodeIP, now time.Time) {
+	deleteProviderHealth(namespace, name)
+	for _, n := range nodeIPs {
+		for _, h := range worker.HealthFromStatus(n.ProviderHealth) {
+			labels := prometheus.Labels{"namespace": namespace, "name": name, "node_label": n.NodeLabel, "provider": h.Provider}
+			providerHealthScore.With(labels).Set(h.Score())
+			providerLatency.With(labels).Set(h.Latency.Seconds())
+			providerConsecutiveFailures.With(labels).Set(float64(h.ConsecutiveFailures))
+			open := 0.0
+			if h.OpenUntil.After(now) {
+				open = 1
+			}
+			providerCircuitOpen.With(labels).Set(open)
+		}
+	}
+}
+
+func deleteProviderHealth(namespace, name string) {
+	for _, g := range providerGauges {
+		g.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+	}
+}
+
+// recordCompliance exports if the node IPs of a node label are within the expected CIDRs.
+func recordCompliance(namespace, name, nodeLabel string, compliant bool) {
+	value := 0.0
+	if compliant {
+		value = 1
+	}
+	nodeIPCompliant.With(prometheus.Labels{"namespace": namespace, "name": name, "node_label": nodeLabel}).Set(value)
+}
+
+func deleteCompliance(namespace, name string) {
+	nodeIPCompliant.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+}
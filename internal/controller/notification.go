@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+)
+
+// notifier delivers the node IPs to a single notification target.
+type notifier struct {
+	// name identifies the target in the spec, because several targets may have the same URL.
+	name    string
+	target  string
+	pending func(delivered []v1beta1.NodeIP) bool
+	deliver func(ctx context.Context, delivered []v1beta1.NodeIP) error
+}
+
+func (r *ClusterIPReconciler) reader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func (r *ClusterIPReconciler) secretValue(ctx context.Context, namespace string, ref *corev1.SecretKeySelector) ([]byte, error) {
+	var secret corev1.Secret
+	if err := r.reader().Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, err
+	}
+	return secret.Data[ref.Key], nil
+}
+
+// matches returns if the status records the delivery to the target of the notifier. A target whose URL changed
+// starts with a new status.
+func (n notifier) matches(status v1beta1.NotificationStatus) bool {
+	return status.Name == n.name && status.Target == n.target
+}
+
+func notificationStatus(statuses []v1beta1.NotificationStatus, n notifier) *v1beta1.NotificationStatus {
+	for i := range statuses {
+		if n.matches(statuses[i]) {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// deliveredState returns what is recorded about the node IPs after they were delivered.
+func deliveredState(nodeIPs []v1beta1.NodeIP) []v1beta1.DeliveredNodeIP {
+	result := []v1beta1.DeliveredNodeIP{}
+	for _, n := range nodeIPs {
+		result = append(result, v1beta1.DeliveredNodeIP{NodeLabel: n.NodeLabel, IPs: n.IPs(), Error: n.Error})
+	}
+	return result
+}
+
+// deliveredNodeIPs returns the recorded state as node IPs to compare them with the current ones.
+func deliveredNodeIPs(delivered []v1beta1.DeliveredNodeIP) []v1beta1.NodeIP {
+	var result []v1beta1.NodeIP
+	for _, d := range delivered {
+		n := v1beta1.NodeIP{NodeLabel: d.NodeLabel, Error: d.Error}
+		for _, ip := range d.IPs {
+			n.Addresses = append(n.Addresses, v1beta1.NewAddress(ip))
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+func (r *ClusterIPReconciler) notifiers(clusterIP v1beta1.ClusterIPObject) []notifier {
+	spec, status := clusterIP.GetSpec(), clusterIP.GetStatus()
+	if spec.Notifications == nil {
+		return nil
+	}
+	var result []notifier
+	current := status.NodeIPs
+	for i, w := range spec.Notifications.Webhooks {
+		w := w
+		result = append(result, notifier{
+			name:   fmt.Sprintf("webhooks[%d]", i),
+			target: w.URL,
+			pending: func(delivered []v1beta1.NodeIP) bool {
+				// webhooks receive only complete IP sets
+				return status.State == "Ready" && !slices.Equal(notification.IPs(delivered), notification.IPs(current))
+			},
+			deliver: func(ctx context.Context, delivered []v1beta1.NodeIP) error {
+				webhook := notification.Webhook{URL: w.URL, Headers: w.Headers}
+				if w.SecretRef != nil {
+					secret, err := r.secretValue(ctx, r.namespace(clusterIP), w.SecretRef)
+					if err != nil {
+						return err
+					}
+					webhook.Secret = secret
+				}
+				return r.Notifier.SendWebhook(ctx, webhook, notification.Payload{
+					Name:      clusterIP.GetName(),
+					Namespace: clusterIP.GetNamespace(),
+					OldIPs:    notification.IPs(delivered),
+					NewIPs:    notification.IPs(current),
+					NodeIPs:   current,
+					Timestamp: time.Now().UTC(),
+				})
+			},
+		})
+	}
+	if ce := spec.Notifications.CloudEvents; ce != nil {
+		source := fmt.Sprintf("/apis/%s/namespaces/%s/clusterips/%s", v1beta1.GroupVersion, clusterIP.GetNamespace(), clusterIP.GetName())
+		if clusterIP.GetNamespace() == "" {
+			source = fmt.Sprintf("/apis/%s/globalclusterips/%s", v1beta1.GroupVersion, clusterIP.GetName())
+		}
+		result = append(result, notifier{
+			name:   "cloudEvents",
+			target: ce.Sink,
+			pending: func(delivered []v1beta1.NodeIP) bool {
+				return len(notification.Changes(source, delivered, current)) > 0
+			},
+			deliver: func(ctx context.Context, delivered []v1beta1.NodeIP) error {
+				for _, e := range notification.Changes(source, delivered, current) {
+					if err := r.Notifier.SendCloudEvent(ctx, ce.Sink, ce.Mode, ce.Headers, e); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+	return result
+}
+
+// Notify delivers the current node IPs to all configured notification targets whose last delivered state differs.
+// Every target gets a single attempt per reconciliation. Failures are recorded in the notification status and
+// retried after notification.Backoff, so that an unreachable target doesn't block the reconciliation for long.
+// It returns true if the notification status changed and the delay after which failed deliveries should be retried.
+func (r *ClusterIPReconciler) Notify(ctx context.Context, clusterIP v1beta1.ClusterIPObject) (bool, time.Duration) {
+	logger := log.FromContext(ctx)
+	if r.Notifier == nil {
+		r.Notifier = notification.NewSender(10 * time.Second)
+	}
+	notifiers := r.notifiers(clusterIP)
+	status := clusterIP.GetStatus()
+	changed := false
+	statuses := []v1beta1.NotificationStatus{}
+	for _, s := range status.Notifications {
+		if slices.ContainsFunc(notifiers, func(n notifier) bool { return n.matches(s) }) {
+			statuses = append(statuses, s)
+		} else {
+			changed = true
+		}
+	}
+
+	var requeue time.Duration
+	for _, n := range notifiers {
+		target := notificationStatus(statuses, n)
+		if target == nil {
+			statuses = append(statuses, v1beta1.NotificationStatus{Name: n.name, Target: n.target})
+			target = &statuses[len(statuses)-1]
+			changed = true
+		}
+		delivered := deliveredNodeIPs(target.Delivered)
+		if !n.pending(delivered) {
+			continue
+		}
+		if target.Failures > 0 && target.LastAttemptTime != nil {
+			wait := time.Until(target.LastAttemptTime.Add(notification.Backoff(target.Failures)))
+			if wait > 0 {
+				if requeue == 0 || wait < requeue {
+					requeue = wait
+				}
+				continue
+			}
+		}
+
+		err := n.deliver(ctx, delivered)
+		now := metav1.Now()
+		target.LastAttemptTime = &now
+		changed = true
+		if err != nil {
+			logger.Error(err, "Notification failed", "name", n.name, "target", n.target)
+			target.Failures++
+			target.LastError = err.Error()
+			if wait := notification.Backoff(target.Failures); requeue == 0 || wait < requeue {
+				requeue = wait
+			}
+			continue
+		}
+		logger.Info("Notification delivered", "name", n.name, "target", n.target)
+		target.Failures = 0
+		target.LastError = ""
+		target.LastSuccessTime = &now
+		target.Delivered = deliveredState(status.NodeIPs)
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	status.Notifications = statuses
+	return changed, requeue
+}
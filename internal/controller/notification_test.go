@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/notification"
+)
+
+func TestNotify(t *testing.T) {
+	calls, fail := 0, false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+		Spec:       v1beta1.ClusterIPSpec{Notifications: &v1beta1.Notifications{Webhooks: []v1beta1.WebhookNotification{{URL: server.URL}}}},
+		Status: v1beta1.ClusterIPStatus{State: "Ready", NodeIPs: []v1beta1.NodeIP{{
+			NodeLabel: "a",
+			Addresses: []v1beta1.Address{v1beta1.NewAddress("1.2.3.4")},
+			Evidence:  []v1beta1.Evidence{{Provider: "ipinfo.io", IP: "1.2.3.4"}},
+			Metadata:  &v1beta1.IPMetadata{Country: "DE"},
+		}}},
+	}
+	r, _ := newReconciler(t)
+	r.Notifier = notification.NewSender(time.Second)
+	ctx := context.Background()
+
+	if changed, retry := r.Notify(ctx, clusterIP); !changed || retry != 0 || calls != 1 {
+		t.Fatalf("expected delivery, got changed %t, retry %v, %d calls", changed, retry, calls)
+	}
+	expected := []v1beta1.DeliveredNodeIP{{NodeLabel: "a", IPs: []string{"1.2.3.4"}}}
+	if delivered := clusterIP.Status.Notifications[0].Delivered; !reflect.DeepEqual(delivered, expected) {
+		t.Errorf("expected delivered state %+v, got %+v", expected, delivered)
+	}
+	if changed, _ := r.Notify(ctx, clusterIP); changed || calls != 1 {
+		t.Errorf("expected no delivery of the same IPs, got changed %t, %d calls", changed, calls)
+	}
+
+	fail = true
+	clusterIP.Status.NodeIPs[0].Addresses = []v1beta1.Address{v1beta1.NewAddress("5.6.7.8")}
+	_, retry := r.Notify(ctx, clusterIP)
+	target := clusterIP.Status.Notifications[0]
+	if calls != 2 || target.Failures != 1 || retry != notification.Backoff(1) {
+		t.Errorf("expected a single failed attempt retried after %v, got %d calls, %d failures, retry %v", notification.Backoff(1), calls, target.Failures, retry)
+	}
+	if _, retry := r.Notify(ctx, clusterIP); calls != 2 || retry <= 0 {
+		t.Errorf("expected no attempt within the backoff, got %d calls, retry %v", calls, retry)
+	}
+}
+
+func TestNotifyTargetsWithSameURL(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Header.Get("X-Target")]++
+		if r.Header.Get("X-Target") == "failing" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default"},
+		Spec: v1beta1.ClusterIPSpec{Notifications: &v1beta1.Notifications{Webhooks: []v1beta1.WebhookNotification{
+			{URL: server.URL, Headers: map[string]string{"X-Target": "failing"}},
+			{URL: server.URL, Headers: map[string]string{"X-Target": "working"}},
+		}}},
+		Status: v1beta1.ClusterIPStatus{State: "Ready", NodeIPs: []v1beta1.NodeIP{{
+			NodeLabel: "a",
+			Addresses: []v1beta1.Address{v1beta1.NewAddress("1.2.3.4")},
+		}}},
+	}
+	r, _ := newReconciler(t)
+	r.Notifier = notification.NewSender(time.Second)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		r.Notify(ctx, clusterIP)
+	}
+	statuses := clusterIP.Status.Notifications
+	if len(statuses) != 2 || statuses[0].Name != "webhooks[0]" || statuses[1].Name != "webhooks[1]" {
+		t.Fatalf("expected a status per webhook, got %+v", statuses)
+	}
+	if statuses[0].Failures != 1 || statuses[0].Delivered != nil || statuses[1].Failures != 0 || len(statuses[1].Delivered) != 1 {
+		t.Errorf("expected separate delivery states, got %+v", statuses)
+	}
+	// the failing target waits for its backoff, and the delivered one has nothing new
+	if calls["failing"] != 1 || calls["working"] != 1 {
+		t.Errorf("expected a single call per target, got %v", calls)
+	}
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/status"
+)
+
+// RemoveNodeIP removes the node IP entry of the node label which is gone from the cluster.
+func (r *ClusterIPReconciler) RemoveNodeIP(ctx context.Context, clusterIP v1beta1.ClusterIPObject, nodeLabel string) error {
+	return status.RemoveNodeIP(ctx, r.Client, clusterIP, nodeLabel)
+}
+
+// ApplyManagerStatus writes the status fields owned by the manager.
+func (r *ClusterIPReconciler) ApplyManagerStatus(ctx context.Context, clusterIP v1beta1.ClusterIPObject) error {
+	current := clusterIP.GetStatus()
+	return status.Apply(ctx, r.Client, clusterIP, status.ManagerFieldOwner, func(status *v1beta1.ClusterIPStatus) {
+		status.State = current.State
+		status.Info = current.Info
+		status.Conditions = current.Conditions
+		status.Notifications = current.Notifications
+		status.LastRefreshRequest = current.LastRefreshRequest
+		status.LastRefreshTime = current.LastRefreshTime
+	})
+}
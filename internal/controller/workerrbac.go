@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// workerServiceAccount runs the worker pods. Its cluster role only allows to read the ClusterIPs, to patch their status
+// and to create events. The secrets and config maps referenced from a spec are granted by a role per ClusterIP.
+const workerServiceAccount = "cluster-ip-worker"
+
+// workerRoleName returns the name of the role granting the workers of the ClusterIP access to its secrets and config maps.
+func workerRoleName(clusterIP v1beta1.ClusterIPObject) string {
+	if clusterIP.GetNamespace() == "" {
+		return "cluster-ip-worker-global-" + clusterIP.GetName()
+	}
+	return "cluster-ip-worker-" + clusterIP.GetName()
+}
+
+// workerReferences returns the names of the secrets and config maps the workers read for the spec.
+func workerReferences(spec *v1beta1.ClusterIPSpec) (secrets []string, configMaps []string) {
+	configs := []v1beta1.HTTPConfig{}
+	if spec.HTTP != nil {
+		configs = append(configs, *spec.HTTP)
+	}
+	for _, p := range spec.Providers {
+		configs = append(configs, p.HTTPConfig)
+	}
+	add := func(names []string, name string) []string {
+		if name == "" || slices.Contains(names, name) {
+			return names
+		}
+		return append(names, name)
+	}
+	for _, c := range configs {
+		if c.ProxyURLSecretRef != nil {
+			secrets = add(secrets, c.ProxyURLSecretRef.Name)
+		}
+		if c.CABundle != nil && c.CABundle.SecretKeyRef != nil {
+			secrets = add(secrets, c.CABundle.SecretKeyRef.Name)
+		}
+		if c.CABundle != nil && c.CABundle.ConfigMapKeyRef != nil {
+			configMaps = add(configMaps, c.CABundle.ConfigMapKeyRef.Name)
+		}
+		if c.ClientCertificateSecretRef != nil {
+			secrets = add(secrets, c.ClientCertificateSecretRef.Name)
+		}
+		for _, h := range c.HeadersFrom {
+			secrets = add(secrets, h.SecretKeyRef.Name)
+		}
+	}
+	slices.Sort(secrets)
+	slices.Sort(configMaps)
+	return secrets, configMaps
+}
+
+// ReconcileWorkerRBAC grants the worker service account read access to the secrets and config maps referenced
+// from the spec, by name only. The role is deleted when the spec references none, because a rule without
+// resource names would grant access to all of them.
+func (r *ClusterIPReconciler) ReconcileWorkerRBAC(ctx context.Context, clusterIP v1beta1.ClusterIPObject) error {
+	meta := metav1.ObjectMeta{Name: workerRoleName(clusterIP), Namespace: r.namespace(clusterIP)}
+	role := &rbacv1.Role{ObjectMeta: meta}
+	binding := &rbacv1.RoleBinding{ObjectMeta: meta}
+	secrets, configMaps := workerReferences(clusterIP.GetSpec())
+	if len(secrets) == 0 && len(configMaps) == 0 {
+		for _, obj := range []client.Object{binding, role} {
+			if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	var rules []rbacv1.PolicyRule
+	if len(secrets) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: secrets, Verbs: []string{"get"}})
+	}
+	if len(configMaps) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: configMaps, Verbs: []string{"get"}})
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Rules = rules
+		return controllerutil.SetControllerReference(clusterIP, role, r.Scheme)
+	}); err != nil {
+		return err
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		binding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: workerServiceAccount, Namespace: r.SystemNamespace}}
+		return controllerutil.SetControllerReference(clusterIP, binding, r.Scheme)
+	})
+	return err
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+func TestReconcileWorkerRBAC(t *testing.T) {
+	ctx := context.Background()
+	secretKey := func(name string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: "key"}
+	}
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "default", UID: "uid"},
+		Spec: v1beta1.ClusterIPSpec{
+			HTTP: &v1beta1.HTTPConfig{
+				ProxyURLSecretRef: secretKey("proxy"),
+				CABundle:          &v1beta1.CABundleSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"}},
+			},
+			Providers: []v1beta1.Provider{{
+				Name: "echo",
+				HTTPConfig: v1beta1.HTTPConfig{
+					ClientCertificateSecretRef: &corev1.LocalObjectReference{Name: "client-cert"},
+					HeadersFrom:                []v1beta1.HeaderSource{{Name: "Authorization", SecretKeyRef: *secretKey("proxy")}},
+				},
+			}},
+		},
+	}
+	r, _ := newReconciler(t, clusterIP)
+	if err := r.ReconcileWorkerRBAC(ctx, clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "cluster-ip-worker-egress"}
+	var role rbacv1.Role
+	if err := r.Get(ctx, key, &role); err != nil {
+		t.Fatal(err)
+	}
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"client-cert", "proxy"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"ca"}, Verbs: []string{"get"}},
+	}
+	if !equality.Semantic.DeepEqual(role.Rules, expected) {
+		t.Errorf("expected rules %+v, got %+v", expected, role.Rules)
+	}
+	if owner := metav1.GetControllerOf(&role); owner == nil || owner.UID != "uid" {
+		t.Errorf("expected the ClusterIP to own the role, got %+v", owner)
+	}
+	var binding rbacv1.RoleBinding
+	if err := r.Get(ctx, key, &binding); err != nil {
+		t.Fatal(err)
+	}
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: workerServiceAccount, Namespace: "kyma-system"}
+	if len(binding.Subjects) != 1 || binding.Subjects[0] != subject || binding.RoleRef.Name != role.Name {
+		t.Errorf("unexpected binding %+v", binding)
+	}
+
+	// without references the role would have to grant all secrets, so it is removed
+	clusterIP.Spec = v1beta1.ClusterIPSpec{}
+	if err := r.ReconcileWorkerRBAC(ctx, clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, key, &role); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the role to be deleted, got %v", err)
+	}
+	if err := r.Get(ctx, key, &binding); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the binding to be deleted, got %v", err)
+	}
+}
+
+func TestReconcileWorkerRBACGlobal(t *testing.T) {
+	ctx := context.Background()
+	clusterIP := &v1beta1.GlobalClusterIP{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress"},
+		Spec: v1beta1.ClusterIPSpec{HTTP: &v1beta1.HTTPConfig{
+			CABundle: &v1beta1.CABundleSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}, Key: "ca.crt"}},
+		}},
+	}
+	r, _ := newReconciler(t, clusterIP)
+	if err := r.ReconcileWorkerRBAC(ctx, clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	var role rbacv1.Role
+	if err := r.Get(ctx, types.NamespacedName{Namespace: "kyma-system", Name: "cluster-ip-worker-global-egress"}, &role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 1 || role.Rules[0].Resources[0] != "secrets" || len(role.Rules[0].ResourceNames) != 1 {
+		t.Errorf("unexpected rules %+v", role.Rules)
+	}
+}
@@ -0,0 +1,91 @@
+package echo
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// Main runs the echo server with the command line arguments until it is terminated and returns the exit code.
+func Main(args []string) int {
+	fs := flag.NewFlagSet("echo-server", flag.ExitOnError)
+	var addr, trusted, headers, certFile, keyFile, signingKeyFile string
+	var proxyProtocol bool
+	fs.StringVar(&addr, "bind-address", ":8080", "The address the echo server binds to.")
+	fs.StringVar(&trusted, "trusted-proxies", "", "Comma separated CIDRs of the proxies whose forwarded headers and PROXY protocol headers are used, like 10.0.0.0/8. Use 0.0.0.0/0,::/0 if every peer is a proxy.")
+	fs.StringVar(&headers, "forwarded-headers", HeaderXForwardedFor, "Comma separated forwarded headers of trusted proxies checked in order: X-Forwarded-For, X-Real-IP or Forwarded.")
+	fs.BoolVar(&proxyProtocol, "proxy-protocol", false, "Expect the PROXY protocol header, version 1 or 2, on connections of trusted proxies.")
+	fs.StringVar(&certFile, "tls-cert-file", "", "The certificate to serve HTTPS with.")
+	fs.StringVar(&keyFile, "tls-key-file", "", "The key of the certificate.")
+	fs.StringVar(&signingKeyFile, "signing-key-file", "", "The PEM encoded Ed25519 private key to sign the responses with.")
+	zapOpts := zap.Options{Development: true}
+	zapOpts.BindFlags(fs)
+	fs.Parse(args)
+	log.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	logger := log.Log.WithName("echo-server")
+
+	proxies, err := ParseTrustedProxies(trusted)
+	if err != nil {
+		logger.Error(err, "invalid --trusted-proxies")
+		return 2
+	}
+	handler := &Handler{TrustedProxies: proxies}
+	for _, h := range strings.Split(headers, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			handler.Headers = append(handler.Headers, h)
+		}
+	}
+	if signingKeyFile != "" {
+		data, err := os.ReadFile(signingKeyFile)
+		if err == nil {
+			handler.SigningKey, err = ParsePrivateKey(data)
+		}
+		if err != nil {
+			logger.Error(err, "invalid --signing-key-file")
+			return 2
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Error(err, "unable to listen", "address", addr)
+		return 1
+	}
+	if proxyProtocol {
+		listener = &ProxyListener{Listener: listener, TrustedProxies: proxies}
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("starting echo server", "address", addr, "trustedProxies", trusted, "proxyProtocol", proxyProtocol, "signed", handler.SigningKey != nil)
+	if certFile != "" {
+		err = server.ServeTLS(listener, certFile, keyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err, "echo server failed")
+		return 1
+	}
+	return 0
+}
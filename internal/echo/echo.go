@@ -0,0 +1,189 @@
+// Package echo serves the address requests come from, so that teams can run their own IP provider
+// outside the cluster instead of depending on public services.
+package echo
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Response is the JSON answer of the echo server.
+// Responses of a server with a signing key also hold the signature, see Sign.
+type Response struct {
+	IP        string `json:"ip"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Header names of the supported forwarded headers.
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+	HeaderForwarded     = "Forwarded"
+)
+
+// TrustedProxies are the networks of the proxies in front of the echo server. Only their forwarded headers
+// and PROXY protocol headers are used, because any client can send them.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma separated list of CIDRs or addresses.
+func ParseTrustedProxies(value string) (TrustedProxies, error) {
+	var result TrustedProxies
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
+// Contains returns if the address belongs to a trusted proxy.
+func (t TrustedProxies) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler answers with the address of the client, as JSON or, for the text format, as plain text.
+type Handler struct {
+	// TrustedProxies may set the client address with the Headers.
+	TrustedProxies TrustedProxies
+	// Headers are the forwarded headers checked in order. The first one present is used.
+	Headers []string
+	// SigningKey signs the JSON responses if set, including the nonce query parameter.
+	SigningKey ed25519.PrivateKey
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addr, err := h.ClientAddr(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(addr.String() + "\n"))
+		return
+	}
+	response := Response{IP: addr.String()}
+	if h.SigningKey != nil {
+		nonce := r.URL.Query().Get("nonce")
+		if len(nonce) > MaxNonceLength {
+			http.Error(w, fmt.Sprintf("nonce exceeds %d characters", MaxNonceLength), http.StatusBadRequest)
+			return
+		}
+		response.Sign(h.SigningKey, time.Now(), nonce)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ClientAddr returns the address of the client. If the request comes from a trusted proxy, the address is taken
+// from the forwarded headers: the last address of the chain not belonging to a trusted proxy.
+func (h *Handler) ClientAddr(r *http.Request) (netip.Addr, error) {
+	peer, err := parseAddr(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !h.TrustedProxies.Contains(peer) {
+		return peer, nil
+	}
+	for _, header := range h.Headers {
+		chain := forwardedChain(r.Header, header)
+		if len(chain) == 0 {
+			continue
+		}
+		for i := len(chain) - 1; i >= 0; i-- {
+			addr, err := parseAddr(chain[i])
+			if err != nil {
+				return netip.Addr{}, err
+			}
+			if i == 0 || !h.TrustedProxies.Contains(addr) {
+				return addr, nil
+			}
+		}
+	}
+	return peer, nil
+}
+
+// forwardedChain returns the addresses of the header, from the client to the last proxy.
+func forwardedChain(header http.Header, name string) []string {
+	var chain []string
+	for _, value := range header.Values(name) {
+		for _, element := range strings.Split(value, ",") {
+			element = strings.TrimSpace(element)
+			if http.CanonicalHeaderKey(name) == HeaderForwarded {
+				element = forwardedFor(element)
+			}
+			if element != "" {
+				chain = append(chain, element)
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedFor returns the for parameter of an element of the Forwarded header of RFC 7239.
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "for") {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// parseAddr parses an address with an optional port, like 1.2.3.4, 1.2.3.4:80, 2001:db8::1 or [2001:db8::1]:80.
+func parseAddr(value string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+func wantsText(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "text"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json")
+}
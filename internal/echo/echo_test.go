@@ -0,0 +1,175 @@
+package echo
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientAddr(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		ip      string
+	}{
+		{"direct client", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted peer can't spoof", "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"chain of proxies", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 192.0.2.1"}, "198.51.100.1"},
+		{"only proxies", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "10.0.0.1, 10.0.0.2"}, "10.0.0.1"},
+		{"second header", "10.1.2.3:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"forwarded", "10.1.2.3:1234", map[string]string{"Forwarded": `for="[2001:db8::1]:80";proto=https, for=10.0.0.1`}, "2001:db8::1"},
+		{"no header", "10.1.2.3:1234", nil, "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TrustedProxies: proxies, Headers: []string{HeaderXForwardedFor, HeaderXRealIP, HeaderForwarded}}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			addr, err := h.ClientAddr(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if addr.String() != tt.ip {
+				t.Errorf("expected %s, got %s", tt.ip, addr)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	h := &Handler{}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var response Response
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.IP != "203.0.113.7" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected JSON response %q, %v", w.Body.String(), err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?format=text", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.String() != "203.0.113.7\n" {
+		t.Errorf("unexpected text response %q", w.Body.String())
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	v2 := append([]byte{}, proxySignature...)
+	v2 = append(v2, 0x21, 0x11, 0, 12, 198, 51, 100, 1, 10, 0, 0, 1)
+	v2 = binary.BigEndian.AppendUint16(v2, 56324)
+	v2 = binary.BigEndian.AppendUint16(v2, 443)
+	local := append(append([]byte{}, proxySignature...), 0x20, 0x00, 0, 0)
+	tests := []struct {
+		name   string
+		header string
+		remote string
+		valid  bool
+	}{
+		{"version 1", "PROXY TCP4 198.51.100.1 10.0.0.1 56324 443\r\n", "198.51.100.1:56324", true},
+		{"version 1 ipv6", "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", true},
+		{"version 1 unknown", "PROXY UNKNOWN\r\n", "", true},
+		{"version 2", string(v2), "198.51.100.1:56324", true},
+		{"version 2 local", string(local), "", true},
+		{"missing header", "GET / HTTP/1.1\r\n", "", false},
+		{"mismatched family", "PROXY TCP4 2001:db8::1 10.0.0.1 56324 443\r\n", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, err := readProxyHeader(bufio.NewReader(strings.NewReader(tt.header + "GET / HTTP/1.1\r\n")))
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid %t, got %v", tt.valid, err)
+			}
+			if tt.remote == "" && remote != nil || tt.remote != "" && (remote == nil || remote.String() != tt.remote) {
+				t.Errorf("expected remote %q, got %v", tt.remote, remote)
+			}
+		})
+	}
+}
+
+func TestProxyListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, _ := ParseTrustedProxies("127.0.0.1")
+	server := &http.Server{Handler: &Handler{}}
+	go server.Serve(&ProxyListener{Listener: l, TrustedProxies: proxies})
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("PROXY TCP4 198.51.100.1 10.0.0.1 56324 80\r\nGET /?format=text HTTP/1.1\r\nHost: echo\r\n\r\n"))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body := make([]byte, 64)
+	n, _ := res.Body.Read(body)
+	if string(body[:n]) != "198.51.100.1\n" {
+		t.Errorf("unexpected response %q", body[:n])
+	}
+}
+
+func TestSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	signed := func(modify func(*Response)) []byte {
+		r := Response{IP: "203.0.113.7"}
+		r.Sign(private, now, "nonce")
+		if modify != nil {
+			modify(&r)
+		}
+		body, _ := json.Marshal(r)
+		return body
+	}
+	tests := []struct {
+		name  string
+		body  []byte
+		nonce string
+		now   time.Time
+		valid bool
+	}{
+		{"valid", signed(nil), "nonce", now, true},
+		{"other nonce", signed(nil), "other", now, false},
+		{"replayed", signed(nil), "nonce", now.Add(2 * MaxSignatureAge), false},
+		{"changed IP", signed(func(r *Response) { r.IP = "198.51.100.1" }), "nonce", now, false},
+		{"unsigned", []byte(`{"ip":"203.0.113.7"}`), "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := Verify(public, tt.body, tt.nonce, tt.now)
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid %t, got %v", tt.valid, err)
+			}
+			if tt.valid && ip != "203.0.113.7" {
+				t.Errorf("unexpected IP %s", ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package echo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout limits how long a connection may take to send the PROXY protocol header.
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxySignature starts the binary header of version 2 of the PROXY protocol.
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyListener accepts connections of load balancers sending the PROXY protocol header, version 1 or 2,
+// and reports the address of the client from the header as remote address. Connections of other peers
+// are used as they are, so that clients can't spoof their address with a header.
+type ProxyListener struct {
+	net.Listener
+	TrustedProxies TrustedProxies
+}
+
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	peer, err := parseAddr(conn.RemoteAddr().String())
+	if err != nil || !l.TrustedProxies.Contains(peer) {
+		return conn, nil
+	}
+	// The header is read on first use, so that a slow proxy doesn't block accepting other connections.
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.err = readProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			c.err = fmt.Errorf("invalid PROXY protocol header from %s: %w", c.Conn.RemoteAddr(), c.err)
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads the PROXY protocol header. It returns a nil address for health checks of the proxy itself,
+// which are sent with the LOCAL command or the UNKNOWN protocol.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(len(proxySignature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(start, proxySignature) {
+		return readProxyHeaderV2(r)
+	}
+	return readProxyHeaderV1(r)
+}
+
+// readProxyHeaderV1 reads the text header, like "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	// The longest header has 107 bytes.
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("missing header line")
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errors.New("missing PROXY signature")
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("expected 6 fields, got %d", len(fields))
+	}
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	if addr.Is4() != (fields[1] == "TCP4") {
+		return nil, fmt.Errorf("address %s doesn't match protocol %s", addr, fields[1])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readProxyHeaderV2 reads the binary header: the signature, the version and command, the address family,
+// the length of the addresses and the addresses.
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxySignature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	versionCommand, family := header[12], header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", versionCommand>>4)
+	}
+	addresses := make([]byte, length)
+	if _, err := io.ReadFull(r, addresses); err != nil {
+		return nil, err
+	}
+	switch versionCommand & 0x0f {
+	case 0x0: // LOCAL
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported command %d", versionCommand&0x0f)
+	}
+	var size int
+	switch family >> 4 {
+	case 0x1: // AF_INET
+		size = 4
+	case 0x2: // AF_INET6
+		size = 16
+	default:
+		return nil, nil
+	}
+	if len(addresses) < 2*size+4 {
+		return nil, fmt.Errorf("expected at least %d address bytes, got %d", 2*size+4, len(addresses))
+	}
+	addr, _ := netip.AddrFromSlice(addresses[:size])
+	port := binary.BigEndian.Uint16(addresses[2*size:])
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr.Unmap(), port)), nil
+}
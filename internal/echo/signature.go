@@ -0,0 +1,77 @@
+package echo
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxSignatureAge is how far the timestamp of a signed response may differ from the clock of the worker.
+const MaxSignatureAge = time.Minute
+
+// MaxNonceLength limits the nonce the echo server signs.
+const MaxNonceLength = 128
+
+// Sign signs the address, the current time and the nonce sent by the worker, so that a proxy between
+// the worker and the echo server can't answer with another address, or replay an earlier answer.
+func (r *Response) Sign(key ed25519.PrivateKey, now time.Time, nonce string) {
+	r.Timestamp = now.UTC().Format(time.RFC3339)
+	r.Nonce = nonce
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, r.message()))
+}
+
+// message is the signed content of the response.
+func (r *Response) message() []byte {
+	return []byte(fmt.Sprintf("cluster-ip-echo/v1\nip=%s\ntimestamp=%s\nnonce=%s", r.IP, r.Timestamp, r.Nonce))
+}
+
+// Verify checks that the response body is signed by the key for the nonce, and returns the signed address.
+func Verify(key ed25519.PublicKey, body []byte, nonce string, now time.Time) (string, error) {
+	var r Response
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", err
+	}
+	if r.Signature == "" {
+		return "", errors.New("response is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(key, r.message(), signature) {
+		return "", errors.New("signature doesn't match the public key")
+	}
+	if r.Nonce != nonce {
+		return "", fmt.Errorf("response is signed for nonce %q instead of %q", r.Nonce, nonce)
+	}
+	timestamp, err := time.Parse(time.RFC3339, r.Timestamp)
+	if err != nil {
+		return "", fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if age := now.Sub(timestamp); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return "", fmt.Errorf("response is signed at %s, more than %s from now", r.Timestamp, MaxSignatureAge)
+	}
+	return r.IP, nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8 Ed25519 key, as generated by openssl genpkey -algorithm ed25519.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ed25519Key, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an Ed25519 key, got %T", key)
+	}
+	return ed25519Key, nil
+}
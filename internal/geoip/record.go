@@ -0,0 +1,110 @@
+// Package geoip looks up the location and network of IPs in local databases in the MaxMind DB format,
+// like GeoLite2-Country, GeoLite2-City and GeoLite2-ASN, for clusters which can't query geolocation services.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Record is the location and network of an IP.
+type Record struct {
+	// Country is the ISO 3166-1 alpha-2 code, like DE.
+	Country string
+	City    string
+	ASN     uint32
+	Org     string
+	// Databases are the types of the databases the record comes from, like GeoLite2-City.
+	Databases []string
+}
+
+// IsZero returns if nothing is known about the IP.
+func (r Record) IsZero() bool {
+	return r.Country == "" && r.City == "" && r.ASN == 0 && r.Org == ""
+}
+
+func (r Record) known() int {
+	count := 0
+	for _, set := range []bool{r.Country != "", r.City != "", r.ASN != 0, r.Org != ""} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// data are the fields of the GeoIP2 and GeoLite2 schema a Record is made of.
+type data struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN uint32 `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// Databases are looked up together, because locations and networks are usually distributed in separate databases.
+type Databases []*maxminddb.Reader
+
+// OpenAll reads the database files.
+func OpenAll(paths []string) (Databases, error) {
+	var result Databases
+	for _, file := range paths {
+		r, err := maxminddb.Open(file)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("%s: %w", file, err), result.Close())
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Close releases the database files.
+func (d Databases) Close() error {
+	var errs []error
+	for _, r := range d {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Lookup merges the data of the address in the databases with the GeoIP2 and GeoLite2 schema.
+// The first database having a field wins.
+func (d Databases) Lookup(addr netip.Addr) (Record, error) {
+	var result Record
+	for _, r := range d {
+		var value data
+		if err := r.Lookup(addr.Unmap().AsSlice(), &value); err != nil {
+			return Record{}, err
+		}
+		known := result.known()
+		if result.Country == "" {
+			result.Country = strings.ToUpper(value.Country.ISOCode)
+		}
+		if result.Country == "" {
+			result.Country = strings.ToUpper(value.RegisteredCountry.ISOCode)
+		}
+		if result.City == "" {
+			result.City = value.City.Names["en"]
+		}
+		if result.ASN == 0 {
+			result.ASN = value.ASN
+		}
+		if result.Org == "" {
+			result.Org = value.Org
+		}
+		if result.known() > known {
+			result.Databases = append(result.Databases, r.Metadata.DatabaseType)
+		}
+	}
+	return result, nil
+}
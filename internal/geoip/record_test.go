@@ -0,0 +1,50 @@
+package geoip
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// openTestDatabases opens the test databases published by MaxMind, see testdata/README.md.
+func openTestDatabases(t *testing.T) Databases {
+	t.Helper()
+	databases, err := OpenAll([]string{"testdata/GeoLite2-City-Test.mmdb", "testdata/GeoLite2-ASN-Test.mmdb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { databases.Close() })
+	return databases
+}
+
+func TestLookup(t *testing.T) {
+	databases := openTestDatabases(t)
+	tests := []struct {
+		ip       string
+		expected Record
+	}{
+		{"81.2.69.142", Record{Country: "GB", City: "London", Databases: []string{"GeoLite2-City"}}},
+		{"::ffff:81.2.69.142", Record{Country: "GB", City: "London", Databases: []string{"GeoLite2-City"}}},
+		{"1.128.0.1", Record{ASN: 1221, Org: "Telstra Pty Ltd", Databases: []string{"GeoLite2-ASN"}}},
+		{"2001:218::1", Record{Country: "JP", Databases: []string{"GeoLite2-City"}}},
+		{"198.51.100.1", Record{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			record, err := databases.Lookup(netip.MustParseAddr(tt.ip))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Country != tt.expected.Country || record.City != tt.expected.City || record.ASN != tt.expected.ASN ||
+				record.Org != tt.expected.Org || strings.Join(record.Databases, ",") != strings.Join(tt.expected.Databases, ",") {
+				t.Errorf("expected %+v, got %+v", tt.expected, record)
+			}
+		})
+	}
+}
+
+func TestOpenInvalidDatabase(t *testing.T) {
+	if _, err := OpenAll([]string{"testdata/GeoLite2-City-Test.mmdb", "testdata/README.md"}); err == nil || !strings.Contains(err.Error(), "README.md") {
+		t.Errorf("expected error for the file which isn't a database, got %v", err)
+	}
+}
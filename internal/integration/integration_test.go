@@ -39,9 +39,16 @@ func TestClusterIP(t *testing.T) {
 	}
 	nodes := gjson.Get(json, "items")
 
-	out, err := command("kubectl", "apply", "-f", "../../config/samples/cluster-ip-nodes.yaml")
+	// the admission webhook rejects requests until the manager serves it
+	err = retry(func() error {
+		out, err := command("kubectl", "apply", "-f", "../../config/samples/cluster-ip-nodes.yaml")
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return nil
+	}, time.Second, 30)
 	if err != nil {
-		t.Fatal(err, out)
+		t.Fatal(err)
 	}
 
 	err = retry(func() error {
@@ -59,7 +66,7 @@ func TestClusterIP(t *testing.T) {
 				return fmt.Errorf("in the cluster with %v nodes %v IPs received", numNodes, numIPs)
 			}
 			for _, node := range status.Get("nodeIPs").Array() {
-				t.Log("node:", node.Get("nodeLabel").String(), ",IP:", node.Get("ip"))
+				t.Log("node:", node.Get("nodeLabel").String(), ",IP:", node.Get("addresses.#.ip"))
 			}
 		}
 		return nil
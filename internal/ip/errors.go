@@ -0,0 +1,86 @@
+package ip
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Reason classifies why the answer of a provider was rejected.
+type Reason string
+
+const (
+	ReasonRequestFailed Reason = "RequestFailed"
+	ReasonHTTPStatus    Reason = "HTTPStatus"
+	ReasonBodyTooLarge  Reason = "BodyTooLarge"
+	ReasonContentType   Reason = "ContentType"
+	ReasonDecodeFailed  Reason = "DecodeFailed"
+	ReasonIPNotFound    Reason = "IPNotFound"
+	ReasonInvalidIP     Reason = "InvalidIP"
+	ReasonCircuitOpen   Reason = "CircuitOpen"
+	// ReasonRateLimited is a 429 or 503 response. The provider is skipped for the time given by its Retry-After header.
+	ReasonRateLimited     Reason = "RateLimited"
+	ReasonBudgetExhausted Reason = "BudgetExhausted"
+	// ReasonInvalidSignature is an echo server answer which isn't signed by the configured key for the nonce of the request.
+	ReasonInvalidSignature Reason = "InvalidSignature"
+	// ReasonNonPublicIP is a private, CGNAT or other special-purpose address not allowed by the policy.
+	ReasonNonPublicIP Reason = "NonPublicIP"
+)
+
+// ProviderError is the reason of a rejected provider answer, with the HTTP status code if a response was received.
+type ProviderError struct {
+	Reason     Reason
+	StatusCode int
+	// RetryAfter is how long the provider asked not to be called again.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+func providerError(reason Reason, format string, args ...any) *ProviderError {
+	return &ProviderError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// Reason returns why the provider answer was rejected, or an empty reason if it was accepted.
+func (e Evidence) Reason() Reason {
+	var pe *ProviderError
+	if errors.As(e.Err, &pe) {
+		return pe.Reason
+	}
+	if e.Err != nil {
+		return ReasonRequestFailed
+	}
+	return ""
+}
+
+// StatusCode returns the HTTP status code of the provider response, or 0 if no response was received.
+func (e Evidence) StatusCode() int {
+	var pe *ProviderError
+	if errors.As(e.Err, &pe) {
+		return pe.StatusCode
+	}
+	return 0
+}
+
+// retryAfter parses the Retry-After header, given in seconds or as HTTP date, relative to now.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
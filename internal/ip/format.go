@@ -0,0 +1,101 @@
+package ip
+
+import (
+	"encoding/json"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Extractor returns the IP from the response body of a provider with the given content type.
+type Extractor func(contentType string, body []byte) (string, error)
+
+// JSON extracts the IP from the string at the gjson path, like ip or data.client.ip.
+// The response must be a valid JSON document. Content types of other documents, like HTML error pages of proxies, are rejected.
+func JSON(path string) Extractor {
+	return func(contentType string, body []byte) (string, error) {
+		if !jsonContentType(contentType) {
+			return "", providerError(ReasonContentType, "unexpected content type %q, expected application/json", contentType)
+		}
+		var document any
+		if err := json.Unmarshal(body, &document); err != nil {
+			return "", providerError(ReasonDecodeFailed, "invalid JSON response: %v", err)
+		}
+		value := gjson.GetBytes(body, path)
+		if !value.Exists() || value.Type == gjson.Null {
+			return "", providerError(ReasonIPNotFound, "missing or nil IP field '%s' in response", path)
+		}
+		if value.Type != gjson.String {
+			return "", providerError(ReasonIPNotFound, "invalid IP format: expected string at '%s', got %s", path, value.Type)
+		}
+		return value.Str, nil
+	}
+}
+
+// Text takes the whole response body without surrounding whitespace as the IP.
+// The response must have a text content type if the provider sends one.
+func Text() Extractor {
+	return func(contentType string, body []byte) (string, error) {
+		if mediaType := mediaType(contentType); mediaType != "" && !strings.HasPrefix(mediaType, "text/") {
+			return "", providerError(ReasonContentType, "unexpected content type %q, expected text", contentType)
+		}
+		ip := strings.TrimSpace(string(body))
+		if ip == "" {
+			return "", providerError(ReasonIPNotFound, "empty response")
+		}
+		return ip, nil
+	}
+}
+
+// Regex extracts the IP matched by the pattern, or by its first capture group if it has one.
+// The content type is not checked, as the pattern can match any response.
+func Regex(pattern string) (Extractor, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(contentType string, body []byte) (string, error) {
+		match := re.FindSubmatch(body)
+		if match == nil {
+			return "", providerError(ReasonIPNotFound, "response doesn't match %s", pattern)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}, nil
+}
+
+// Echo extracts the IP from the JSON response of the echo server of the operator, which always sends application/json.
+func Echo() Extractor {
+	extract := JSON("ip")
+	return func(contentType string, body []byte) (string, error) {
+		if mediaType := mediaType(contentType); mediaType != "application/json" {
+			return "", providerError(ReasonContentType, "unexpected content type %q, expected application/json", contentType)
+		}
+		return extract(contentType, body)
+	}
+}
+
+// jsonContentType returns if the content type can hold JSON. Some providers send JSON as plain text or JavaScript.
+func jsonContentType(contentType string) bool {
+	switch mediaType := mediaType(contentType); mediaType {
+	case "", "application/json", "text/plain", "text/javascript", "application/javascript":
+		return true
+	default:
+		return strings.HasSuffix(mediaType, "+json")
+	}
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
@@ -0,0 +1,50 @@
+package ip
+
+import "testing"
+
+func TestExtractors(t *testing.T) {
+	regex, err := Regex(`Current IP Address: ([0-9.]+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wholeMatch, err := Regex(`\d+\.\d+\.\d+\.\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		extract     Extractor
+		contentType string
+		body        string
+		ip          string
+		valid       bool
+	}{
+		{"top-level json", JSON("ip"), "application/json; charset=utf-8", `{"ip":"1.2.3.4"}`, "1.2.3.4", true},
+		{"nested json", JSON("data.client.ip"), "application/vnd.api+json", `{"data":{"client":{"ip":"1.2.3.4"}}}`, "1.2.3.4", true},
+		{"missing json field", JSON("data.client.ip"), "", `{"data":{}}`, "", false},
+		{"null json field", JSON("ip"), "", `{"ip":null}`, "", false},
+		{"non-string json field", JSON("ip"), "", `{"ip":1234}`, "", false},
+		{"invalid json", JSON("ip"), "", `{"ip":"1.2.3.4"`, "", false},
+		{"json with wrong content type", JSON("ip"), "text/html", `{"ip":"1.2.3.4"}`, "", false},
+		{"text with wrong content type", Text(), "application/json", `1.2.3.4`, "", false},
+		{"empty text", Text(), "", "\n", "", false},
+		{"text", Text(), "text/plain", "1.2.3.4\n", "1.2.3.4", true},
+		{"regex group", regex, "text/html", "<body>Current IP Address: 1.2.3.4</body>", "1.2.3.4", true},
+		{"regex match", wholeMatch, "", "you are 1.2.3.4", "1.2.3.4", true},
+		{"regex mismatch", regex, "text/html", "<body>unknown</body>", "", false},
+		{"echo", Echo(), "application/json", `{"ip":"1.2.3.4"}`, "1.2.3.4", true},
+		{"echo without content type", Echo(), "", `{"ip":"1.2.3.4"}`, "", false},
+	}
+	for _, tt := range tests {
+		ip, err := tt.extract(tt.contentType, []byte(tt.body))
+		if tt.valid && (err != nil || ip != tt.ip) {
+			t.Errorf("%s: expected %s, got %q, %v", tt.name, tt.ip, ip, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected error, got %q", tt.name, ip)
+		}
+	}
+	if _, err := Regex(`(`); err == nil {
+		t.Error("expected invalid pattern error")
+	}
+}
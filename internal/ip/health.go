@@ -0,0 +1,196 @@
+package ip
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures opening the circuit of a provider.
+	DefaultFailureThreshold = 3
+	// DefaultCoolDown is how long a provider with an open circuit is skipped.
+	DefaultCoolDown = 10 * time.Minute
+
+	// maxSamples bounds the counters, so that the success rate follows recent behavior.
+	maxSamples = 100
+	// latencyWeight is the weight of a new sample in the moving average of the latency.
+	latencyWeight = 0.3
+)
+
+// Health is the statistics of a provider.
+type Health struct {
+	Provider            string
+	Successes           int
+	Failures            int
+	ConsecutiveFailures int
+	// Latency is the moving average of the response time.
+	Latency time.Duration
+	// OpenUntil is when the circuit of the provider closes again. The provider is skipped until then.
+	OpenUntil time.Time
+	// Requests is the number of requests to a provider with a budget since WindowStart, the start of the budget period.
+	Requests    int
+	WindowStart time.Time
+}
+
+// Score rates the provider between 0 and 1 from its success rate, lowered by consecutive failures.
+func (h Health) Score() float64 {
+	rate := float64(h.Successes+1) / float64(h.Successes+h.Failures+2)
+	return rate / float64(1+h.ConsecutiveFailures)
+}
+
+// Tracker keeps the health of the providers and opens the circuit of a provider failing repeatedly.
+// The requests to providers with a budget are counted together with the usage of other workers added with AddUsage.
+type Tracker struct {
+	mu        sync.Mutex
+	health    map[string]*Health
+	usage     map[string][]usage
+	threshold int
+	coolDown  time.Duration
+	now       func() time.Time
+}
+
+// usage is the number of requests to a provider made by another worker in the budget period starting at windowStart.
+type usage struct {
+	windowStart time.Time
+	requests    int
+}
+
+func NewTracker(threshold int, coolDown time.Duration) *Tracker {
+	return &Tracker{health: map[string]*Health{}, usage: map[string][]usage{}, threshold: threshold, coolDown: coolDown, now: time.Now}
+}
+
+// AddUsage adds the requests to the provider made by another worker in the budget period starting at windowStart.
+func (t *Tracker) AddUsage(provider string, windowStart time.Time, requests int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage[provider] = append(t.usage[provider], usage{windowStart: windowStart, requests: requests})
+}
+
+// used returns the requests to the provider in the budget period starting at windowStart.
+func (t *Tracker) used(provider string, windowStart time.Time) int {
+	used := 0
+	if h := t.get(provider); h.WindowStart.Equal(windowStart) {
+		used = h.Requests
+	}
+	for _, u := range t.usage[provider] {
+		if u.windowStart.Equal(windowStart) {
+			used += u.requests
+		}
+	}
+	return used
+}
+
+// Load restores the health recorded earlier, like by a previous worker run.
+func (t *Tracker) Load(health []Health) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, h := range health {
+		h := h
+		t.health[h.Provider] = &h
+	}
+}
+
+// Snapshot returns the health of all known providers sorted by name.
+func (t *Tracker) Snapshot() []Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]Health, 0, len(t.health))
+	for _, h := range t.health {
+		result = append(result, *h)
+	}
+	slices.SortFunc(result, func(a, b Health) int { return cmp.Compare(a.Provider, b.Provider) })
+	return result
+}
+
+func (t *Tracker) get(provider string) *Health {
+	h, ok := t.health[provider]
+	if !ok {
+		h = &Health{Provider: provider}
+		t.health[provider] = h
+	}
+	return h
+}
+
+// Record adds the outcome of a request to the provider.
+func (t *Tracker) Record(provider string, latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.get(provider)
+	if h.Successes+h.Failures >= maxSamples {
+		h.Successes, h.Failures = h.Successes/2, h.Failures/2
+	}
+	if h.Latency == 0 {
+		h.Latency = latency
+	} else {
+		h.Latency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(h.Latency))
+	}
+	if err == nil {
+		h.Successes++
+		h.ConsecutiveFailures = 0
+		h.OpenUntil = time.Time{}
+		return
+	}
+	h.Failures++
+	h.ConsecutiveFailures++
+	if h.ConsecutiveFailures >= t.threshold {
+		h.OpenUntil = t.now().Add(t.coolDown)
+	}
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.Reason == ReasonRateLimited && pe.RetryAfter > 0 {
+		if until := t.now().Add(pe.RetryAfter); until.After(h.OpenUntil) {
+			h.OpenUntil = until
+		}
+	}
+}
+
+// Select returns the providers to ask, healthy ones first, and the evidence of the skipped ones. Providers with an
+// open circuit are skipped, unless fewer than quorum providers are left, then the best rated of them are asked as well
+// to still meet the quorum. Providers with an exhausted budget are always skipped. The selected requests are counted.
+func (t *Tracker) Select(services []IPService, quorum int) (selected []IPService, skipped []Evidence) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	var closed, open []IPService
+	for _, s := range services {
+		if s.budget.Requests > 0 && s.budget.Period > 0 {
+			if used := t.used(s.name, now.Truncate(s.budget.Period)); used >= s.budget.Requests {
+				skipped = append(skipped, Evidence{Provider: s.name, Err: providerError(ReasonBudgetExhausted, "budget of %d requests per %s exhausted", s.budget.Requests, s.budget.Period)})
+				continue
+			}
+		}
+		if now.Before(t.get(s.name).OpenUntil) {
+			open = append(open, s)
+		} else {
+			closed = append(closed, s)
+		}
+	}
+	better := func(a, b IPService) int {
+		ha, hb := t.health[a.name], t.health[b.name]
+		if c := cmp.Compare(hb.Score(), ha.Score()); c != 0 {
+			return c
+		}
+		return cmp.Compare(ha.Latency, hb.Latency)
+	}
+	slices.SortStableFunc(closed, better)
+	slices.SortStableFunc(open, better)
+	if missing := quorum - len(closed); missing > 0 {
+		missing = min(missing, len(open))
+		closed, open = append(closed, open[:missing]...), open[missing:]
+	}
+	for _, s := range open {
+		skipped = append(skipped, Evidence{Provider: s.name, Err: providerError(ReasonCircuitOpen, "skipped until %s", t.health[s.name].OpenUntil.UTC().Format(time.RFC3339))})
+	}
+	for _, s := range closed {
+		if s.budget.Requests > 0 && s.budget.Period > 0 {
+			h, windowStart := t.get(s.name), now.Truncate(s.budget.Period)
+			if !h.WindowStart.Equal(windowStart) {
+				h.WindowStart, h.Requests = windowStart, 0
+			}
+			h.Requests++
+		}
+	}
+	return closed, skipped
+}
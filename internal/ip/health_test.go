@@ -0,0 +1,124 @@
+package ip
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTrackerCircuit(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(2, time.Minute)
+	tracker.now = func() time.Time { return now }
+	services := []IPService{{name: "a"}, {name: "b"}, {name: "c"}}
+
+	tracker.Record("a", time.Second, errors.New("timeout"))
+	if selected, _ := tracker.Select(services, 2); len(selected) != 3 || selected[2].name != "a" {
+		t.Errorf("expected a failing provider to be asked last, got %v", names(selected))
+	}
+	tracker.Record("a", time.Second, errors.New("timeout"))
+	selected, skipped := tracker.Select(services, 2)
+	if len(selected) != 2 || len(skipped) != 1 || skipped[0].Provider != "a" || skipped[0].Reason() != ReasonCircuitOpen {
+		t.Errorf("expected open circuit to skip a, got %v, %+v", names(selected), skipped)
+	}
+	if selected, _ := tracker.Select(services, 3); len(selected) != 3 {
+		t.Errorf("expected open circuit to be ignored to meet the quorum, got %v", names(selected))
+	}
+
+	now = now.Add(time.Minute)
+	if selected, _ := tracker.Select(services, 2); len(selected) != 3 {
+		t.Errorf("expected circuit to close after the cool-down, got %v", names(selected))
+	}
+	tracker.Record("a", 100*time.Millisecond, nil)
+	health := tracker.Snapshot()
+	if h := health[0]; h.Provider != "a" || h.ConsecutiveFailures != 0 || !h.OpenUntil.IsZero() || h.Successes != 1 || h.Failures != 2 {
+		t.Errorf("unexpected health %+v", h)
+	}
+
+	restored := NewTracker(2, time.Minute)
+	restored.Load(health)
+	if h := restored.Snapshot()[0]; h != health[0] {
+		t.Errorf("expected %+v, got %+v", health[0], h)
+	}
+}
+
+func TestHealthScore(t *testing.T) {
+	healthy := Health{Successes: 10}
+	flaky := Health{Successes: 5, Failures: 5}
+	failing := Health{Successes: 5, Failures: 5, ConsecutiveFailures: 3}
+	if !(healthy.Score() > flaky.Score() && flaky.Score() > failing.Score()) {
+		t.Errorf("unexpected scores %f, %f, %f", healthy.Score(), flaky.Score(), failing.Score())
+	}
+}
+
+func names(services []IPService) []string {
+	var result []string
+	for _, s := range services {
+		result = append(result, s.name)
+	}
+	return result
+}
+
+func TestTrackerRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	tracker := NewTracker(DefaultFailureThreshold, DefaultCoolDown)
+	limited := NewIPService("limited", server.URL, JSON("ip"), HTTPConfig{}, time.Second)
+	result, err := DiscoverWith([]IPService{limited, {name: "other"}}, 1, tracker, Policy{})
+	if err == nil {
+		t.Fatalf("expected error, got %+v", result)
+	}
+	h := result.Health[slices.IndexFunc(result.Health, func(h Health) bool { return h.Provider == "limited" })]
+	if h.ConsecutiveFailures != 1 || time.Until(h.OpenUntil) < 110*time.Second {
+		t.Errorf("expected circuit open for Retry-After after a single failure, got %+v", h)
+	}
+}
+
+func TestTrackerBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	tracker := NewTracker(DefaultFailureThreshold, DefaultCoolDown)
+	tracker.now = func() time.Time { return now }
+	budgeted := IPService{name: "a"}.WithBudget(Budget{Requests: 3, Period: time.Hour})
+	services := []IPService{budgeted, {name: "b"}}
+
+	tracker.AddUsage("a", now.Truncate(time.Hour), 1)
+	tracker.AddUsage("a", now.Add(-time.Hour).Truncate(time.Hour), 10)
+	for i := 0; i < 2; i++ {
+		if selected, _ := tracker.Select(services, 2); len(selected) != 2 {
+			t.Fatalf("request %d: expected budget left, got %v", i, names(selected))
+		}
+	}
+	selected, skipped := tracker.Select(services, 2)
+	if len(selected) != 1 || len(skipped) != 1 || skipped[0].Reason() != ReasonBudgetExhausted {
+		t.Errorf("expected exhausted budget, got %v, %+v", names(selected), skipped)
+	}
+	if h := tracker.Snapshot()[0]; h.Requests != 2 || !h.WindowStart.Equal(now.Truncate(time.Hour)) {
+		t.Errorf("unexpected requests %+v", h)
+	}
+
+	now = now.Add(time.Hour)
+	if selected, _ := tracker.Select(services, 2); len(selected) != 2 {
+		t.Errorf("expected budget of the next period, got %v", names(selected))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	for header, expected := range map[string]time.Duration{
+		"":   0,
+		"30": 30 * time.Second,
+		"-1": 0,
+		now.Add(time.Minute).Format(http.TimeFormat): time.Minute,
+		"invalid": 0,
+	} {
+		if d := retryAfter(header, now); d != expected {
+			t.Errorf("%q: expected %s, got %s", header, expected, d)
+		}
+	}
+}
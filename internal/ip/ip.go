@@ -1,72 +1,206 @@
 package ip
 
 import (
-	"encoding/json"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
+	"net/netip"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/kyma-project/cluster-ip/internal/echo"
 )
 
 type providerResponse struct {
-	err  error
-	name string
-	ip   string
-	res  http.Response
-	url  string
+	err      error
+	name     string
+	ip       string
+	res      http.Response
+	url      string
+	latency  time.Duration
+	metadata Metadata
 }
 
 type IPService struct {
 	url      string
 	name     string
-	jsonPath string
+	extract  Extractor
+	headers  map[string]string
 	client   *http.Client
+	budget   Budget
+	metadata MetadataPaths
+	// publicKey must have signed the answer of an echo server, together with a nonce sent with every request.
+	publicKey ed25519.PublicKey
+}
+
+// Budget limits the requests to a provider by all workers within aligned periods, like every full hour.
+// A zero budget doesn't limit the requests.
+type Budget struct {
+	Requests int
+	Period   time.Duration
+}
+
+// WithBudget returns the provider limited by the budget.
+func (p IPService) WithBudget(budget Budget) IPService {
+	p.budget = budget
+	return p
+}
+
+// WithPublicKey returns the provider whose answers must be signed with the key by the echo server of the operator.
+func (p IPService) WithPublicKey(key ed25519.PublicKey) IPService {
+	p.publicKey = key
+	return p
+}
+
+// HTTPConfig configures the HTTP client calling a provider.
+type HTTPConfig struct {
+	// Proxy is used instead of the proxy from the environment if set.
+	Proxy *url.URL
+	// RootCAs replace the system certificate pool if set.
+	RootCAs      *x509.CertPool
+	Certificates []tls.Certificate
+	Headers      map[string]string
+}
+
+var defaultClient = &http.Client{Timeout: 5 * time.Second}
+
+// MaxBodySize is the size of the largest accepted provider response. The IP is expected in a small document.
+const MaxBodySize = 64 << 10
+
+// NewIPService returns a provider whose responses hold the IP extracted by extract.
+func NewIPService(name, url string, extract Extractor, config HTTPConfig, timeout time.Duration) IPService {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != nil {
+		transport.Proxy = http.ProxyURL(config.Proxy)
+	}
+	if config.RootCAs != nil || len(config.Certificates) > 0 {
+		transport.TLSClientConfig = &tls.Config{RootCAs: config.RootCAs, Certificates: config.Certificates, MinVersion: tls.VersionTLS12}
+	}
+	return IPService{
+		url:     url,
+		name:    name,
+		extract: extract,
+		headers: config.Headers,
+		client:  &http.Client{Timeout: timeout, Transport: transport},
+	}
 }
 
-func NewIPService(name, url string, timeout time.Duration) *IPService {
-	return &IPService{
-		url:    url,
-		name:   name,
-		client: &http.Client{Timeout: timeout},
+// DefaultProviders returns the built-in providers called with the given configuration.
+func DefaultProviders(config HTTPConfig) []IPService {
+	result := make([]IPService, 0, len(providers))
+	for _, p := range providers {
+		result = append(result, NewIPService(p.name, p.url, p.extract, config, 5*time.Second).WithMetadata(p.metadata))
 	}
+	return result
 }
 
 func (p *IPService) Check() providerResponse {
-	var result map[string]any
-	nt := NewIPService(p.name, p.url, time.Second*5)
-	res, err := nt.client.Get(p.url)
+	start := time.Now()
+	r := p.check()
+	r.latency = time.Since(start)
+	return r
+}
+
+func (p *IPService) check() providerResponse {
+	client := p.client
+	if client == nil {
+		client = defaultClient
+	}
+	target, nonce, err := p.target()
 	if err != nil {
-		return providerResponse{err: err}
+		return providerResponse{name: p.name, url: p.url, err: err}
 	}
-	body, err := io.ReadAll(res.Body)
+	req, err := http.NewRequest(http.MethodGet, target, nil)
 	if err != nil {
-		return providerResponse{err: err}
+		return providerResponse{name: p.name, url: p.url, err: err}
+	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return providerResponse{name: p.name, url: p.url, err: err}
 	}
-	json.Unmarshal(body, &result)
 	defer res.Body.Close()
-	ipVal, ok := result[p.jsonPath]
-	if !ok || ipVal == nil {
-		return providerResponse{err: fmt.Errorf("missing or nil IP field '%s' in response from %s", p.jsonPath, p.url)}
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable {
+		return providerResponse{name: p.name, url: p.url, err: &ProviderError{
+			Reason:     ReasonRateLimited,
+			StatusCode: res.StatusCode,
+			RetryAfter: retryAfter(res.Header.Get("Retry-After"), time.Now()),
+			Err:        fmt.Errorf("unexpected status %s", res.Status),
+		}}
 	}
-
-	ipStr, ok := ipVal.(string)
-	if !ok {
-		return providerResponse{err: fmt.Errorf("invalid IP format: expected string, got %T", ipVal)}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return providerResponse{name: p.name, url: p.url, err: &ProviderError{
+			Reason:     ReasonHTTPStatus,
+			StatusCode: res.StatusCode,
+			Err:        fmt.Errorf("unexpected status %s", res.Status),
+		}}
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, MaxBodySize+1))
+	if err != nil {
+		return providerResponse{name: p.name, url: p.url, err: &ProviderError{Reason: ReasonRequestFailed, StatusCode: res.StatusCode, Err: err}}
+	}
+	if len(body) > MaxBodySize {
+		return providerResponse{name: p.name, url: p.url, err: &ProviderError{
+			Reason:     ReasonBodyTooLarge,
+			StatusCode: res.StatusCode,
+			Err:        fmt.Errorf("response exceeds %d bytes", MaxBodySize),
+		}}
+	}
+	ipStr, err := p.extract(res.Header.Get("Content-Type"), body)
+	if err != nil {
+		if pe, ok := err.(*ProviderError); ok {
+			pe.StatusCode = res.StatusCode
+		}
+		return providerResponse{name: p.name, url: p.url, err: err}
+	}
+	if p.publicKey != nil {
+		signed, err := echo.Verify(p.publicKey, body, nonce, time.Now())
+		if err != nil {
+			return providerResponse{name: p.name, url: p.url, err: &ProviderError{Reason: ReasonInvalidSignature, StatusCode: res.StatusCode, Err: err}}
+		}
+		ipStr = signed
 	}
 
-	return providerResponse{name: p.name, res: *res, url: p.url, ip: ipStr}
+	return providerResponse{name: p.name, res: *res, url: p.url, ip: ipStr, metadata: p.metadata.extract(body)}
+}
+
+// target returns the URL to request. Providers with a public key get a new random nonce, which the answer must be signed for.
+func (p *IPService) target() (string, string, error) {
+	if p.publicKey == nil {
+		return p.url, "", nil
+	}
+	u, err := url.Parse(p.url)
+	if err != nil {
+		return "", "", err
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(random)
+	query := u.Query()
+	query.Set("nonce", nonce)
+	u.RawQuery = query.Encode()
+	return u.String(), nonce, nil
 }
 
 func (p *IPService) Name() string {
 	return p.name
 }
 
+// IsValidIP4 returns if the string is an IPv4 address in dotted decimal notation. Use a Policy to check
+// if it can be an egress IP.
 func IsValidIP4(ipAddress string) bool {
-	ipAddress = strings.Trim(ipAddress, " ")
-	re, _ := regexp.Compile(`^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])$`)
-	return re.MatchString(ipAddress)
+	addr, err := netip.ParseAddr(strings.Trim(ipAddress, " "))
+	return err == nil && addr.Is4()
 }
 
 // worker defines our worker func. as long as there is a job in the
@@ -80,29 +214,87 @@ func worker(jobs <-chan IPService, results chan<- providerResponse) {
 var (
 	providers = []IPService{
 		{
-			name:     "ipwho.is",
-			url:      "https://ipwho.is",
-			jsonPath: "ip",
+			name:    "ipwho.is",
+			url:     "https://ipwho.is",
+			extract: JSON("ip"),
+			metadata: MetadataPaths{
+				Country: "country_code",
+				City:    "city",
+				ASN:     "connection.asn",
+				Org:     "connection.org",
+			},
 		},
 		{
-			name:     "jsonip.com",
-			url:      "https://jsonip.com",
-			jsonPath: "ip",
+			name:    "jsonip.com",
+			url:     "https://jsonip.com",
+			extract: JSON("ip"),
 		},
 		{
-			name:     "ifconfig.me",
-			url:      "https://ifconfig.me/all.json",
-			jsonPath: "ip_addr",
+			name:    "ifconfig.me",
+			url:     "https://ifconfig.me/all.json",
+			extract: JSON("ip_addr"),
 		},
 		{
-			name:     "ipinfo.io",
-			url:      "https://ipinfo.io/json",
-			jsonPath: "ip",
+			name:    "ipinfo.io",
+			url:     "https://ipinfo.io/json",
+			extract: JSON("ip"),
+			// The org holds the ASN, like AS15169 Google LLC.
+			metadata: MetadataPaths{Country: "country", City: "city", Org: "org"},
 		},
 	}
 )
 
+// Evidence is the answer of a single provider.
+type Evidence struct {
+	Provider string
+	IP       string
+	Err      error
+	Latency  time.Duration
+	// Metadata is the location and network of the IP, if the provider returned them.
+	Metadata Metadata
+}
+
+// Result is the IP agreed by the providers together with their answers.
+type Result struct {
+	IP       string
+	Evidence []Evidence
+	// Health is the provider health after the answers were recorded, if a tracker was used.
+	Health []Health
+	// Metadata is the location and network of the IP from the most complete answer of the agreeing providers.
+	Metadata Metadata
+}
+
 func GetIP(min int) (string, error) {
+	result, err := Discover(min)
+	return result.IP, err
+}
+
+// Discover asks the providers for the egress IP until min of them return the same public IP.
+// The returned result contains the evidence collected so far also when an error is returned.
+func Discover(min int) (Result, error) {
+	return discover(providers, min, false, nil, Policy{})
+}
+
+// DiscoverWith is like Discover, but asks the given providers instead of the default ones,
+// and accepts the addresses allowed by the policy.
+// If tracker is set, providers with an open circuit are skipped as long as the quorum can be met without them,
+// and the answers are recorded in the tracker.
+func DiscoverWith(services []IPService, min int, tracker *Tracker, policy Policy) (Result, error) {
+	return discover(services, min, false, tracker, policy)
+}
+
+// Check is like Discover, but waits for the answers of all providers, so that the evidence is complete.
+func Check(min int, tracker *Tracker, policy Policy) (Result, error) {
+	return discover(providers, min, true, tracker, policy)
+}
+
+func discover(providers []IPService, min int, all bool, tracker *Tracker, policy Policy) (Result, error) {
+	var result Result
+	if tracker != nil {
+		var skipped []Evidence
+		providers, skipped = tracker.Select(providers, min)
+		result.Evidence = append(result.Evidence, skipped...)
+	}
 	// Make buffered channels
 	buffer := len(providers)
 	jobsPipe := make(chan IPService, buffer)           // Jobs will be of type `IPService`
@@ -115,25 +307,48 @@ func GetIP(min int) (string, error) {
 	for _, p := range providers {
 		jobsPipe <- p
 	}
+	close(jobsPipe)
 
-	var ip string
+	var mismatch error
 	counter := 0
-	providers := []string{}
-	for i := 0; i < buffer && counter < min; i++ {
+	for i := 0; i < buffer && (all || counter < min); i++ {
 		r := <-resultsPipe
-		if IsValidIP4(r.ip) {
-			counter++
-			providers = append(providers, r.name)
-			if ip == "" {
-				ip = r.ip
-			} else if ip != r.ip {
-				return "", fmt.Errorf("got 2 different IPs: %s, %s", ip, r.ip)
+		e := Evidence{Provider: r.name, IP: r.ip, Err: r.err, Latency: r.latency, Metadata: r.metadata}
+		if e.Err == nil {
+			if err := policy.Check(r.ip); err != nil {
+				if pe, ok := err.(*ProviderError); ok {
+					pe.StatusCode = r.res.StatusCode
+				}
+				e.Err = err
 			}
 		}
-
+		if tracker != nil {
+			tracker.Record(r.name, r.latency, e.Err)
+		}
+		result.Evidence = append(result.Evidence, e)
+		if e.Err != nil {
+			continue
+		}
+		counter++
+		if result.IP == "" {
+			result.IP = r.ip
+		} else if result.IP != r.ip && mismatch == nil {
+			mismatch = fmt.Errorf("got 2 different IPs: %s, %s", result.IP, r.ip)
+			if !all {
+				break
+			}
+		}
+	}
+	var err error
+	if mismatch != nil {
+		result, err = Result{Evidence: result.Evidence}, mismatch
+	} else if counter < min {
+		result, err = Result{Evidence: result.Evidence}, fmt.Errorf("only %d of %d required services returned valid IP", counter, min)
+	} else {
+		result.Metadata = metadata(result.IP, result.Evidence)
 	}
-	if counter < min {
-		return "", fmt.Errorf("only %d of %d required services returned valid IP", counter, min)
+	if tracker != nil {
+		result.Health = tracker.Snapshot()
 	}
-	return ip, nil
+	return result, err
 }
@@ -1,7 +1,16 @@
 package ip
 
 import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/kyma-project/cluster-ip/internal/echo"
 )
 
 func TestValidIP(t *testing.T) {
@@ -13,3 +22,109 @@ func TestValidIP(t *testing.T) {
 		t.Errorf("'%s' expected to be valid IP", clusterIp)
 	}
 }
+
+func TestHTTPConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"ip":"1.2.3.4"}`))
+	}))
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	untrusted := NewIPService("untrusted", server.URL, JSON("ip"), HTTPConfig{Headers: map[string]string{"Authorization": "Bearer token"}}, time.Second)
+	if r := untrusted.Check(); r.err == nil {
+		t.Errorf("expected certificate error, got IP %q", r.ip)
+	}
+	trusted := NewIPService("trusted", server.URL, JSON("ip"), HTTPConfig{RootCAs: pool, Headers: map[string]string{"Authorization": "Bearer token"}}, time.Second)
+	result, err := DiscoverWith([]IPService{trusted}, 1, nil, Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IP != "1.2.3.4" || result.Evidence[0].Provider != "trusted" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestRejectedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ip":"1.2.3.4","padding":"` + strings.Repeat("x", MaxBodySize) + `"}`))
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html>proxy error</html>`))
+		case "/invalid":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ip":`))
+		case "/private":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ip":"10.0.0.1"}`))
+		case "/not-an-ip":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ip":"not-an-ip"}`))
+		}
+	}))
+	defer server.Close()
+	tests := []struct {
+		path       string
+		reason     Reason
+		statusCode int
+	}{
+		{"/unavailable", ReasonRateLimited, http.StatusServiceUnavailable},
+		{"/missing", ReasonHTTPStatus, http.StatusNotFound},
+		{"/large", ReasonBodyTooLarge, http.StatusOK},
+		{"/html", ReasonContentType, http.StatusOK},
+		{"/invalid", ReasonDecodeFailed, http.StatusOK},
+		{"/not-an-ip", ReasonInvalidIP, http.StatusOK},
+		{"/private", ReasonNonPublicIP, http.StatusOK},
+	}
+	var services []IPService
+	for _, tt := range tests {
+		services = append(services, NewIPService(tt.path, server.URL+tt.path, JSON("ip"), HTTPConfig{}, time.Second))
+	}
+	result, err := discover(services, 1, true, nil, Policy{})
+	if err == nil {
+		t.Fatalf("expected error, got %+v", result)
+	}
+	for _, tt := range tests {
+		i := slices.IndexFunc(result.Evidence, func(e Evidence) bool { return e.Provider == tt.path })
+		if i < 0 {
+			t.Fatalf("%s: missing evidence", tt.path)
+		}
+		e := result.Evidence[i]
+		if e.Reason() != tt.reason || e.StatusCode() != tt.statusCode {
+			t.Errorf("%s: expected %s (%d), got %s (%d): %v", tt.path, tt.reason, tt.statusCode, e.Reason(), e.StatusCode(), e.Err)
+		}
+	}
+}
+
+func TestSignedEcho(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(&echo.Handler{SigningKey: private})
+	defer server.Close()
+
+	signed := NewIPService("signed", server.URL, Echo(), HTTPConfig{}, time.Second).WithPublicKey(public)
+	if r := signed.Check(); r.err != nil || r.ip != "127.0.0.1" {
+		t.Errorf("expected 127.0.0.1, got %q, %v", r.ip, r.err)
+	}
+	forged := NewIPService("forged", server.URL, Echo(), HTTPConfig{}, time.Second).WithPublicKey(other)
+	if r := forged.Check(); (Evidence{Err: r.err}).Reason() != ReasonInvalidSignature {
+		t.Errorf("expected invalid signature, got %q, %v", r.ip, r.err)
+	}
+}
@@ -0,0 +1,103 @@
+package ip
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Metadata describes where an IP is located and which network it belongs to, as reported by a provider.
+type Metadata struct {
+	// Country is the ISO 3166-1 alpha-2 code, like DE.
+	Country string
+	City    string
+	// ASN is the number of the autonomous system announcing the IP.
+	ASN uint32
+	// Org is the organization owning the autonomous system or the IP, like a cloud provider.
+	Org string
+	// Source is the provider the metadata comes from.
+	Source string
+}
+
+// IsZero returns if no metadata is known.
+func (m Metadata) IsZero() bool {
+	return m.Country == "" && m.City == "" && m.ASN == 0 && m.Org == ""
+}
+
+func (m Metadata) fields() int {
+	count := 0
+	for _, set := range []bool{m.Country != "", m.City != "", m.ASN != 0, m.Org != ""} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
+
+// MetadataPaths are the gjson paths of the metadata in the JSON response of a provider. Empty paths are skipped.
+type MetadataPaths struct {
+	Country string
+	City    string
+	// ASN can hold a number or a string like AS15169.
+	ASN string
+	// Org can start with the ASN, like AS15169 Google LLC, which is then used if there is no ASN path.
+	Org string
+}
+
+// WithMetadata returns the provider whose responses hold the metadata at the paths.
+func (p IPService) WithMetadata(paths MetadataPaths) IPService {
+	p.metadata = paths
+	return p
+}
+
+func (m MetadataPaths) extract(body []byte) Metadata {
+	var result Metadata
+	get := func(path string) gjson.Result {
+		if path == "" {
+			return gjson.Result{}
+		}
+		return gjson.GetBytes(body, path)
+	}
+	result.Country = strings.ToUpper(strings.TrimSpace(get(m.Country).String()))
+	result.City = strings.TrimSpace(get(m.City).String())
+	if asn := get(m.ASN); asn.Type == gjson.Number {
+		result.ASN = uint32(asn.Uint())
+	} else {
+		result.ASN, _ = parseASN(asn.String())
+	}
+	result.Org = strings.TrimSpace(get(m.Org).String())
+	if asn, org := parseASN(result.Org); asn != 0 {
+		if result.ASN == 0 {
+			result.ASN = asn
+		}
+		result.Org = org
+	}
+	return result
+}
+
+// parseASN parses a string starting with an ASN, like AS15169 or AS15169 Google LLC, and returns the ASN and the rest.
+func parseASN(value string) (uint32, string) {
+	value = strings.TrimSpace(value)
+	if len(value) < 3 || !strings.EqualFold(value[:2], "AS") {
+		return 0, value
+	}
+	number, rest, _ := strings.Cut(value[2:], " ")
+	asn, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return 0, value
+	}
+	return uint32(asn), strings.TrimSpace(rest)
+}
+
+// metadata returns the most complete metadata of the providers agreeing on the IP.
+func metadata(ip string, evidence []Evidence) Metadata {
+	var result Metadata
+	for _, e := range evidence {
+		if e.Err == nil && e.IP == ip && e.Metadata.fields() > result.fields() {
+			result = e.Metadata
+			result.Source = e.Provider
+		}
+	}
+	return result
+}
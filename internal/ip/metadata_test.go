@@ -0,0 +1,56 @@
+package ip
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    MetadataPaths
+		body     string
+		expected Metadata
+	}{
+		{
+			name:     "ipwho.is",
+			paths:    providers[0].metadata,
+			body:     `{"ip":"34.1.2.3","country_code":"de","city":"Frankfurt am Main","connection":{"asn":396982,"org":"Google LLC"}}`,
+			expected: Metadata{Country: "DE", City: "Frankfurt am Main", ASN: 396982, Org: "Google LLC"},
+		},
+		{
+			name:     "ipinfo.io",
+			paths:    providers[3].metadata,
+			body:     `{"ip":"34.1.2.3","city":"Frankfurt am Main","country":"DE","org":"AS396982 Google LLC"}`,
+			expected: Metadata{Country: "DE", City: "Frankfurt am Main", ASN: 396982, Org: "Google LLC"},
+		},
+		{
+			name:     "string ASN",
+			paths:    MetadataPaths{ASN: "network.asn", Org: "network.name"},
+			body:     `{"network":{"asn":"AS16509","name":"Amazon.com, Inc."}}`,
+			expected: Metadata{ASN: 16509, Org: "Amazon.com, Inc."},
+		},
+		{
+			name:  "missing fields",
+			paths: MetadataPaths{Country: "country", ASN: "asn"},
+			body:  `{"ip":"34.1.2.3"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m := tt.paths.extract([]byte(tt.body)); m != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, m)
+			}
+		})
+	}
+
+	evidence := []Evidence{
+		{Provider: "partial", IP: "34.1.2.3", Metadata: Metadata{Country: "DE"}},
+		{Provider: "other-ip", IP: "34.9.9.9", Metadata: Metadata{Country: "US", City: "Dallas", ASN: 1, Org: "Other"}},
+		{Provider: "failed", Err: errors.New("timeout")},
+		{Provider: "complete", IP: "34.1.2.3", Metadata: Metadata{Country: "DE", ASN: 396982, Org: "Google LLC"}},
+	}
+	if m := metadata("34.1.2.3", evidence); m.Source != "complete" || m.ASN != 396982 {
+		t.Errorf("expected the metadata of the most complete agreeing answer, got %+v", m)
+	}
+}
@@ -0,0 +1,102 @@
+package ip
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// AddressClass is a range of special-purpose addresses, which can't be the public egress IP of a cluster.
+type AddressClass string
+
+const (
+	// ClassPrivate are the private networks of RFC 1918 and the unique local IPv6 addresses.
+	ClassPrivate AddressClass = "Private"
+	// ClassCGNAT is the shared address space of carrier-grade NAT, 100.64.0.0/10.
+	ClassCGNAT         AddressClass = "CGNAT"
+	ClassLoopback      AddressClass = "Loopback"
+	ClassLinkLocal     AddressClass = "LinkLocal"
+	ClassDocumentation AddressClass = "Documentation"
+	ClassUnspecified   AddressClass = "Unspecified"
+	ClassMulticast     AddressClass = "Multicast"
+	// ClassReserved are the other ranges which are not routed in the internet, like the benchmarking and the future use ranges.
+	ClassReserved AddressClass = "Reserved"
+)
+
+type specialRange struct {
+	prefix netip.Prefix
+	class  AddressClass
+}
+
+var specialPurpose = []specialRange{
+	{netip.MustParsePrefix("0.0.0.0/8"), ClassUnspecified},
+	{netip.MustParsePrefix("10.0.0.0/8"), ClassPrivate},
+	{netip.MustParsePrefix("100.64.0.0/10"), ClassCGNAT},
+	{netip.MustParsePrefix("127.0.0.0/8"), ClassLoopback},
+	{netip.MustParsePrefix("169.254.0.0/16"), ClassLinkLocal},
+	{netip.MustParsePrefix("172.16.0.0/12"), ClassPrivate},
+	{netip.MustParsePrefix("192.0.0.0/24"), ClassReserved},
+	{netip.MustParsePrefix("192.0.2.0/24"), ClassDocumentation},
+	{netip.MustParsePrefix("192.168.0.0/16"), ClassPrivate},
+	{netip.MustParsePrefix("198.18.0.0/15"), ClassReserved},
+	{netip.MustParsePrefix("198.51.100.0/24"), ClassDocumentation},
+	{netip.MustParsePrefix("203.0.113.0/24"), ClassDocumentation},
+	{netip.MustParsePrefix("224.0.0.0/4"), ClassMulticast},
+	{netip.MustParsePrefix("240.0.0.0/4"), ClassReserved},
+	{netip.MustParsePrefix("::/128"), ClassUnspecified},
+	{netip.MustParsePrefix("::1/128"), ClassLoopback},
+	{netip.MustParsePrefix("100::/64"), ClassReserved},
+	{netip.MustParsePrefix("2001:db8::/32"), ClassDocumentation},
+	{netip.MustParsePrefix("fc00::/7"), ClassPrivate},
+	{netip.MustParsePrefix("fe80::/10"), ClassLinkLocal},
+	{netip.MustParsePrefix("ff00::/8"), ClassMulticast},
+}
+
+// Classify returns the special-purpose class of the address, or an empty class for a public address.
+func Classify(addr netip.Addr) AddressClass {
+	addr = addr.Unmap()
+	for _, s := range specialPurpose {
+		if s.prefix.Contains(addr) {
+			return s.class
+		}
+	}
+	return ""
+}
+
+// Policy decides which addresses returned by the providers are accepted.
+// The zero policy accepts only public IPv4 addresses.
+type Policy struct {
+	// Allow are the special-purpose classes which are accepted anyway, like Private to discover the egress IP
+	// of a cluster within a private network.
+	Allow []AddressClass
+}
+
+// ParsePolicy returns the policy allowing the named classes.
+func ParsePolicy(allow []string) (Policy, error) {
+	var policy Policy
+	for _, name := range allow {
+		class := AddressClass(strings.TrimSpace(name))
+		if class == "" {
+			continue
+		}
+		if !slices.ContainsFunc(specialPurpose, func(s specialRange) bool { return s.class == class }) {
+			return Policy{}, fmt.Errorf("unknown address class %q", name)
+		}
+		policy.Allow = append(policy.Allow, class)
+	}
+	return policy, nil
+}
+
+// Check returns why the address isn't accepted, or nil if it is. The error is a *ProviderError
+// with the InvalidIP reason for anything but an IPv4 address, or the NonPublicIP reason.
+func (p Policy) Check(ip string) error {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil || !addr.Is4() {
+		return providerError(ReasonInvalidIP, "invalid IP: %q", ip)
+	}
+	if class := Classify(addr); class != "" && !slices.Contains(p.Allow, class) {
+		return providerError(ReasonNonPublicIP, "%s is a %s address, which is not allowed", addr, class)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package ip
+
+import (
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	private, err := ParsePolicy([]string{"Private", " CGNAT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePolicy([]string{"Public"}); err == nil {
+		t.Error("expected error for unknown class")
+	}
+	tests := []struct {
+		ip      string
+		policy  Policy
+		reason  Reason
+		allowed bool
+	}{
+		{"34.1.2.3", Policy{}, "", true},
+		{" 34.1.2.3 ", Policy{}, "", true},
+		{"10.1.2.3", Policy{}, ReasonNonPublicIP, false},
+		{"172.31.255.1", Policy{}, ReasonNonPublicIP, false},
+		{"172.32.0.1", Policy{}, "", true},
+		{"192.168.1.1", Policy{}, ReasonNonPublicIP, false},
+		{"100.64.0.1", Policy{}, ReasonNonPublicIP, false},
+		{"127.0.0.1", Policy{}, ReasonNonPublicIP, false},
+		{"169.254.169.254", Policy{}, ReasonNonPublicIP, false},
+		{"203.0.113.7", Policy{}, ReasonNonPublicIP, false},
+		{"0.0.0.0", Policy{}, ReasonNonPublicIP, false},
+		{"255.255.255.255", Policy{}, ReasonNonPublicIP, false},
+		{"10.1.2.3", private, "", true},
+		{"100.127.255.254", private, "", true},
+		{"127.0.0.1", private, ReasonNonPublicIP, false},
+		{"2001:4860::1", Policy{}, ReasonInvalidIP, false},
+		{"::ffff:34.1.2.3", Policy{}, ReasonInvalidIP, false},
+		{"034.1.2.3", Policy{}, ReasonInvalidIP, false},
+		{"not-an-ip", Policy{}, ReasonInvalidIP, false},
+	}
+	for _, tt := range tests {
+		err := tt.policy.Check(tt.ip)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%q: expected allowed %t, got %v", tt.ip, tt.allowed, err)
+			continue
+		}
+		if reason := (Evidence{Err: err}).Reason(); reason != tt.reason {
+			t.Errorf("%q: expected reason %q, got %q", tt.ip, tt.reason, reason)
+		}
+	}
+}
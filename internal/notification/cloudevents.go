@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+const (
+	EventDiscovered = "io.kyma.clusterip.ip.discovered"
+	EventChanged    = "io.kyma.clusterip.ip.changed"
+	EventRemoved    = "io.kyma.clusterip.ip.removed"
+	EventFailed     = "io.kyma.clusterip.ip.failed"
+
+	ModeBinary     = "binary"
+	ModeStructured = "structured"
+
+	specVersion = "1.0"
+)
+
+// Event is a CloudEvent describing the lifecycle of a single node IP.
+type Event struct {
+	ID      string
+	Source  string
+	Type    string
+	Subject string
+	Time    time.Time
+	Data    EventData
+}
+
+type EventData struct {
+	NodeLabel   string   `json:"nodeLabel"`
+	IPs         []string `json:"ips,omitempty"`
+	PreviousIPs []string `json:"previousIPs,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type structuredEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            EventData `json:"data"`
+}
+
+// Changes returns the events needed to bring a receiver which knows the delivered state to the current state.
+func Changes(source string, delivered, current []v1beta1.NodeIP) []Event {
+	var events []Event
+	add := func(eventType string, data EventData) {
+		events = append(events, Event{
+			ID:      newID(),
+			Source:  source,
+			Type:    eventType,
+			Subject: data.NodeLabel,
+			Time:    time.Now().UTC(),
+			Data:    data,
+		})
+	}
+	old := map[string]v1beta1.NodeIP{}
+	for _, n := range delivered {
+		old[n.NodeLabel] = n
+	}
+	for _, n := range current {
+		prev := old[n.NodeLabel]
+		delete(old, n.NodeLabel)
+		switch {
+		case n.Error != "" && n.Error != prev.Error:
+			add(EventFailed, EventData{NodeLabel: n.NodeLabel, IPs: n.IPs(), Error: n.Error})
+		case len(n.Addresses) == 0 || slices.Equal(n.IPs(), prev.IPs()):
+		case len(prev.Addresses) == 0:
+			add(EventDiscovered, EventData{NodeLabel: n.NodeLabel, IPs: n.IPs()})
+		default:
+			add(EventChanged, EventData{NodeLabel: n.NodeLabel, IPs: n.IPs(), PreviousIPs: prev.IPs()})
+		}
+	}
+	for _, n := range delivered {
+		if _, removed := old[n.NodeLabel]; removed && len(n.Addresses) > 0 {
+			add(EventRemoved, EventData{NodeLabel: n.NodeLabel, PreviousIPs: n.IPs()})
+		}
+	}
+	return events
+}
+
+func (s *Sender) SendCloudEvent(ctx context.Context, sink, mode string, headers map[string]string, e Event) error {
+	var body []byte
+	var err error
+	if mode == ModeStructured {
+		body, err = json.Marshal(structuredEvent{
+			SpecVersion:     specVersion,
+			ID:              e.ID,
+			Source:          e.Source,
+			Type:            e.Type,
+			Subject:         e.Subject,
+			Time:            e.Time,
+			DataContentType: "application/json",
+			Data:            e.Data,
+		})
+	} else {
+		body, err = json.Marshal(e.Data)
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if mode == ModeStructured {
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("ce-specversion", specVersion)
+		req.Header.Set("ce-id", e.ID)
+		req.Header.Set("ce-source", e.Source)
+		req.Header.Set("ce-type", e.Type)
+		req.Header.Set("ce-subject", e.Subject)
+		req.Header.Set("ce-time", e.Time.Format(time.RFC3339Nano))
+	}
+	return s.do(req)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+func TestChanges(t *testing.T) {
+	delivered := []v1beta1.NodeIP{{NodeLabel: "a", Addresses: []v1beta1.Address{{IP: "1.1.1.1"}}}, {NodeLabel: "b", Addresses: []v1beta1.Address{{IP: "2.2.2.2"}}}, {NodeLabel: "c", Addresses: []v1beta1.Address{{IP: "3.3.3.3"}}}}
+	current := []v1beta1.NodeIP{{NodeLabel: "a", Addresses: []v1beta1.Address{{IP: "1.1.1.1"}}}, {NodeLabel: "b", Addresses: []v1beta1.Address{{IP: "4.4.4.4"}}}, {NodeLabel: "d", Addresses: []v1beta1.Address{{IP: "5.5.5.5"}}}, {NodeLabel: "e", Error: "timeout"}}
+	expected := []struct{ eventType, label string }{
+		{EventChanged, "b"},
+		{EventDiscovered, "d"},
+		{EventFailed, "e"},
+		{EventRemoved, "c"},
+	}
+	events := Changes("/test", delivered, current)
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i, e := range expected {
+		if events[i].Type != e.eventType || events[i].Subject != e.label {
+			t.Errorf("expected %s for %s, got %+v", e.eventType, e.label, events[i])
+		}
+	}
+	if !slices.Equal(events[0].Data.PreviousIPs, []string{"2.2.2.2"}) || !slices.Equal(events[0].Data.IPs, []string{"4.4.4.4"}) {
+		t.Errorf("unexpected data %+v", events[0].Data)
+	}
+	if len(Changes("/test", current, current)) != 0 {
+		t.Error("expected no events for unchanged state")
+	}
+}
+
+func TestSendCloudEvent(t *testing.T) {
+	event := Event{ID: "1", Source: "/test", Type: EventDiscovered, Subject: "a", Time: time.Now(), Data: EventData{NodeLabel: "a", IPs: []string{"1.1.1.1"}}}
+	for _, mode := range []string{ModeBinary, ModeStructured} {
+		var received Event
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if mode == ModeBinary {
+				received.ID = r.Header.Get("ce-id")
+				received.Type = r.Header.Get("ce-type")
+				json.Unmarshal(body, &received.Data)
+				return
+			}
+			if r.Header.Get("Content-Type") != "application/cloudevents+json" {
+				t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+			}
+			var e structuredEvent
+			json.Unmarshal(body, &e)
+			received = Event{ID: e.ID, Type: e.Type, Data: e.Data}
+		}))
+		err := NewSender(time.Second).SendCloudEvent(context.Background(), server.URL, mode, nil, event)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.ID != event.ID || received.Type != event.Type || !reflect.DeepEqual(received.Data, event.Data) {
+			t.Errorf("%s mode: expected %+v, got %+v", mode, event, received)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+const SignatureHeader = "X-Cluster-IP-Signature"
+
+// Payload is the JSON body posted to webhooks when the set of cluster IPs changes.
+type Payload struct {
+	Name      string           `json:"name"`
+	Namespace string           `json:"namespace,omitempty"`
+	OldIPs    []string         `json:"oldIPs"`
+	NewIPs    []string         `json:"newIPs"`
+	NodeIPs   []v1beta1.NodeIP `json:"nodeIPs"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+type Webhook struct {
+	URL     string
+	Secret  []byte
+	Headers map[string]string
+}
+
+// Sender delivers notifications with a single request each. Failed deliveries are retried by the caller
+// after Backoff, so that an unreachable receiver doesn't block the reconciliation of other ClusterIPs.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+func (s *Sender) SendWebhook(ctx context.Context, w Webhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+	return s.do(req)
+}
+
+func (s *Sender) do(req *http.Request) error {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", req.URL, res.Status)
+	}
+	return nil
+}
+
+// Sign returns the HMAC-SHA256 signature of the body in the "sha256=<hex>" form.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IPs returns the sorted set of distinct IPs of the given entries.
+func IPs(nodeIPs []v1beta1.NodeIP) []string {
+	result := []string{}
+	for _, n := range nodeIPs {
+		for _, ip := range n.IPs() {
+			if !slices.Contains(result, ip) {
+				result = append(result, ip)
+			}
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Backoff returns how long to wait before the next delivery after the given number of consecutive failures.
+func Backoff(failures int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < failures && delay < 10*time.Minute; i++ {
+		delay *= 2
+	}
+	return min(delay, 10*time.Minute)
+}
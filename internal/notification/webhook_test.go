@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+func TestSendWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign([]byte("secret"), body) {
+			t.Errorf("invalid signature %q", r.Header.Get(SignatureHeader))
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("missing custom header")
+		}
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+		if len(payload.NewIPs) != 2 || payload.NewIPs[0] != "1.2.3.4" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+	nodeIPs := []v1beta1.NodeIP{{NodeLabel: "b", Addresses: []v1beta1.Address{{IP: "5.6.7.8"}}}, {NodeLabel: "a", Addresses: []v1beta1.Address{{IP: "1.2.3.4"}}}, {NodeLabel: "c", Addresses: []v1beta1.Address{{IP: "1.2.3.4"}}}}
+	err := sender.SendWebhook(context.Background(), Webhook{
+		URL:     server.URL,
+		Secret:  []byte("secret"),
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}, Payload{Name: "test", NewIPs: IPs(nodeIPs), NodeIPs: nodeIPs})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendWebhookFailure(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewSender(time.Second)
+	if err := sender.SendWebhook(context.Background(), Webhook{URL: server.URL}, Payload{}); err == nil {
+		t.Error("expected error")
+	}
+	// the controller retries after Backoff instead of blocking the reconciliation
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != 10*time.Second || Backoff(2) != 20*time.Second || Backoff(20) != 10*time.Minute {
+		t.Errorf("unexpected backoff %v %v %v", Backoff(1), Backoff(2), Backoff(20))
+	}
+}
@@ -0,0 +1,99 @@
+// Package status writes the ClusterIP status with server-side apply, so that the manager and the workers
+// own distinct parts of it.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// ManagerFieldOwner owns the status fields written by the manager: state, info, conditions, notifications and refresh.
+const ManagerFieldOwner = client.FieldOwner("cluster-ip-manager")
+
+// NodeFieldOwner owns the node IP entry of the node label, no matter if it is written by the worker or the manager.
+func NodeFieldOwner(nodeLabel string) client.FieldOwner {
+	return client.FieldOwner("cluster-ip-node-" + nodeLabel)
+}
+
+// Apply server-side applies the status filled by fill with the given field owner.
+// The applied object contains only the fields set by fill, so concurrent writers of other fields don't overwrite each other.
+// It needs no retry on conflicts: the object is applied without resource version, and the API server merges the
+// node IP entries of the field owners by node label, so concurrent applies of other owners never conflict or get lost.
+func Apply(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, owner client.FieldOwner, fill func(status *v1beta1.ClusterIPStatus)) error {
+	return apply(ctx, c, clusterIP, owner, "", fill)
+}
+
+func apply(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, owner client.FieldOwner, resourceVersion string, fill func(status *v1beta1.ClusterIPStatus)) error {
+	gvk, err := apiutil.GVKForObject(clusterIP, c.Scheme())
+	if err != nil {
+		return err
+	}
+	o, err := c.Scheme().New(gvk)
+	if err != nil {
+		return err
+	}
+	obj := o.(v1beta1.ClusterIPObject)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetName(clusterIP.GetName())
+	obj.SetNamespace(clusterIP.GetNamespace())
+	obj.SetResourceVersion(resourceVersion)
+	fill(obj.GetStatus())
+	if err := c.Status().Patch(ctx, obj, client.Apply, owner, client.ForceOwnership); err != nil {
+		return err
+	}
+	if resourceVersion != "" {
+		clusterIP.SetResourceVersion(obj.GetResourceVersion())
+	}
+	return nil
+}
+
+// ApplyNodeIP writes the node IP entry of a single node label.
+func ApplyNodeIP(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, node v1beta1.NodeIP) error {
+	return Apply(ctx, c, clusterIP, NodeFieldOwner(node.NodeLabel), func(status *v1beta1.ClusterIPStatus) {
+		status.NodeIPs = []v1beta1.NodeIP{node}
+	})
+}
+
+// ApplyNodeIPIfUnchanged writes the node IP entry only if the ClusterIP wasn't changed since it was read.
+// The manager uses it to update entries written by the workers, without overwriting a newer entry with stale addresses.
+// It returns a conflict error otherwise, and updates the resource version of clusterIP on success.
+func ApplyNodeIPIfUnchanged(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, node v1beta1.NodeIP) error {
+	return apply(ctx, c, clusterIP, NodeFieldOwner(node.NodeLabel), clusterIP.GetResourceVersion(), func(status *v1beta1.ClusterIPStatus) {
+		status.NodeIPs = []v1beta1.NodeIP{node}
+	})
+}
+
+// RemoveNodeIP removes the node IP entry of the node label by applying an empty list as its owner.
+// Entries written by older versions with updates are not owned by the field owner of the node label,
+// so an entry left by the apply is removed with a JSON patch. The patch tests the node label at the index,
+// so that it fails with an Invalid error instead of removing another entry if the list changed meanwhile.
+func RemoveNodeIP(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, nodeLabel string) error {
+	if err := Apply(ctx, c, clusterIP, NodeFieldOwner(nodeLabel), func(status *v1beta1.ClusterIPStatus) {}); err != nil {
+		return err
+	}
+	current := clusterIP.DeepCopyObject().(v1beta1.ClusterIPObject)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(clusterIP), current); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(current.GetStatus().NodeIPs, func(n v1beta1.NodeIP) bool { return n.NodeLabel == nodeLabel })
+	if i < 0 {
+		return nil
+	}
+	path := fmt.Sprintf("/status/nodeIPs/%d", i)
+	patch, err := json.Marshal([]map[string]any{
+		{"op": "test", "path": path + "/nodeLabel", "value": nodeLabel},
+		{"op": "remove", "path": path},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Status().Patch(ctx, current, client.RawPatch(types.JSONPatchType, patch))
+}
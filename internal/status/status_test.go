@@ -0,0 +1,154 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// newAPIServerClient starts an API server with the CRDs, because server-side apply and the field ownership
+// of the status entries are implemented by the API server only. The fake client treats apply patches as
+// strategic merge patches. Tests using it are skipped unless the envtest binaries are set up, see make test.
+func newAPIServerClient(t *testing.T) client.Client {
+	t.Helper()
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" && os.Getenv("USE_EXISTING_CLUSTER") != "true" {
+		t.Skip("envtest binaries not found, set KUBEBUILDER_ASSETS or run make test")
+	}
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newClusterIP creates a ClusterIP with a generated name, so that the tests can share an existing cluster.
+func newClusterIP(t *testing.T, c client.Client) *v1beta1.ClusterIP {
+	t.Helper()
+	clusterIP := &v1beta1.ClusterIP{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "status-", Namespace: "default"},
+		Spec:       v1beta1.ClusterIPSpec{NodeSpreadLabel: "status.example.com/test"},
+	}
+	if err := c.Create(context.Background(), clusterIP); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Delete(context.Background(), clusterIP) })
+	return clusterIP
+}
+
+func nodeIP(label, ip string) v1beta1.NodeIP {
+	return v1beta1.NodeIP{NodeLabel: label, Addresses: []v1beta1.Address{v1beta1.NewAddress(ip)}, LastUpdateTime: metav1.Now()}
+}
+
+func TestConcurrentApplyNodeIP(t *testing.T) {
+	c := newAPIServerClient(t)
+	clusterIP := newClusterIP(t, c)
+	ctx := context.Background()
+
+	labels := []string{"zone-a", "zone-b", "zone-c", "zone-d"}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(labels))
+	for i, label := range labels {
+		wg.Add(1)
+		go func(i int, label string) {
+			defer wg.Done()
+			// every writer uses the object read before any entry was written
+			errs <- ApplyNodeIP(ctx, c, clusterIP.DeepCopy(), nodeIP(label, fmt.Sprintf("1.2.3.%d", i)))
+		}(i, label)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Apply(ctx, c, clusterIP.DeepCopy(), ManagerFieldOwner, func(status *v1beta1.ClusterIPStatus) { status.State = "Ready" }); err != nil {
+		t.Fatal(err)
+	}
+
+	var result v1beta1.ClusterIP
+	if err := c.Get(ctx, client.ObjectKeyFromObject(clusterIP), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status.State != "Ready" || len(result.Status.NodeIPs) != len(labels) {
+		t.Fatalf("expected state and %d entries, got %+v", len(labels), result.Status)
+	}
+	for i, label := range labels {
+		found := false
+		for _, n := range result.Status.NodeIPs {
+			found = found || n.NodeLabel == label && n.PrimaryIP() == fmt.Sprintf("1.2.3.%d", i)
+		}
+		if !found {
+			t.Errorf("entry of %s lost: %+v", label, result.Status.NodeIPs)
+		}
+	}
+	// every entry is owned by the field owner of its node label
+	for _, label := range labels {
+		owned := false
+		for _, f := range result.ManagedFields {
+			owned = owned || f.Manager == string(NodeFieldOwner(label)) && f.Operation == metav1.ManagedFieldsOperationApply && f.Subresource == "status"
+		}
+		if !owned {
+			t.Errorf("no apply entry of %s in the managed fields", NodeFieldOwner(label))
+		}
+	}
+}
+
+func TestRemoveNodeIPWrittenBeforeUpgrade(t *testing.T) {
+	c := newAPIServerClient(t)
+	clusterIP := newClusterIP(t, c)
+	ctx := context.Background()
+
+	// older versions updated the whole status, so the field manager of the update owns the entries
+	clusterIP.Status.NodeIPs = []v1beta1.NodeIP{nodeIP("zone-a", "1.2.3.1"), nodeIP("zone-b", "1.2.3.2")}
+	if err := c.Status().Update(ctx, clusterIP, client.FieldOwner("manager")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyNodeIP(ctx, c, clusterIP.DeepCopy(), nodeIP("zone-c", "1.2.3.3")); err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"zone-a", "zone-c"} {
+		if err := RemoveNodeIP(ctx, c, clusterIP.DeepCopy(), label); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// removing an entry which is gone already is a no-op
+	if err := RemoveNodeIP(ctx, c, clusterIP.DeepCopy(), "zone-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	var result v1beta1.ClusterIP
+	if err := c.Get(ctx, client.ObjectKeyFromObject(clusterIP), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Status.NodeIPs) != 1 || result.Status.NodeIPs[0].NodeLabel != "zone-b" {
+		t.Errorf("expected only the entry of zone-b, got %+v", result.Status.NodeIPs)
+	}
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// Main runs the worker with the command line arguments and returns the exit code,
+// which is 1 if the IP can't be determined or reported.
+func Main(args []string) int {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	var opts Options
+	opts.BindFlags(fs)
+	zapOpts := zap.Options{Development: true}
+	zapOpts.BindFlags(fs)
+	fs.Parse(args)
+	log.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	logger := log.Log.WithName("worker")
+
+	c, err := newClient()
+	if err != nil {
+		logger.Error(err, "unable to create client")
+		return 1
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := Run(log.IntoContext(ctx, logger), c, opts); err != nil {
+		logger.Error(err, "worker failed", "node", opts.NodeLabel)
+		return 1
+	}
+	return 0
+}
+
+func newClient() (client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
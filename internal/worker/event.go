@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+)
+
+// recordEvent creates an event for the ClusterIP directly, because the worker exits before an event broadcaster would flush it.
+// Events of cluster-scoped objects are recorded in the default namespace, like the event recorder of the manager does.
+func recordEvent(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, eventType, reason, message string) error {
+	gvk, err := apiutil.GVKForObject(clusterIP, c.Scheme())
+	if err != nil {
+		return err
+	}
+	namespace := clusterIP.GetNamespace()
+	if namespace == "" {
+		namespace = corev1.NamespaceDefault
+	}
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: clusterIP.GetName() + ".", Namespace: namespace},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      gvk.GroupVersion().String(),
+			Kind:            gvk.Kind,
+			Name:            clusterIP.GetName(),
+			Namespace:       clusterIP.GetNamespace(),
+			UID:             clusterIP.GetUID(),
+			ResourceVersion: clusterIP.GetResourceVersion(),
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: "cluster-ip-worker"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	return c.Create(ctx, event)
+}
@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/ip"
+)
+
+// HealthStatus converts the provider health to its status representation.
+func HealthStatus(health []ip.Health) []v1beta1.ProviderHealth {
+	result := make([]v1beta1.ProviderHealth, 0, len(health))
+	for _, h := range health {
+		status := v1beta1.ProviderHealth{
+			Provider:            h.Provider,
+			Successes:           int32(h.Successes),
+			Failures:            int32(h.Failures),
+			ConsecutiveFailures: int32(h.ConsecutiveFailures),
+			LatencyMilliseconds: h.Latency.Milliseconds(),
+			Requests:            int32(h.Requests),
+		}
+		if !h.OpenUntil.IsZero() {
+			openUntil := metav1.NewTime(h.OpenUntil)
+			status.CircuitOpenUntil = &openUntil
+		}
+		if !h.WindowStart.IsZero() {
+			windowStart := metav1.NewTime(h.WindowStart)
+			status.WindowStart = &windowStart
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// HealthFromStatus restores the provider health recorded in the status.
+func HealthFromStatus(status []v1beta1.ProviderHealth) []ip.Health {
+	result := make([]ip.Health, 0, len(status))
+	for _, s := range status {
+		h := ip.Health{
+			Provider:            s.Provider,
+			Successes:           int(s.Successes),
+			Failures:            int(s.Failures),
+			ConsecutiveFailures: int(s.ConsecutiveFailures),
+			Latency:             time.Duration(s.LatencyMilliseconds) * time.Millisecond,
+			Requests:            int(s.Requests),
+		}
+		if s.CircuitOpenUntil != nil {
+			h.OpenUntil = s.CircuitOpenUntil.Time
+		}
+		if s.WindowStart != nil {
+			h.WindowStart = s.WindowStart.Time
+		}
+		result = append(result, h)
+	}
+	return result
+}
@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"maps"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/ip"
+)
+
+// providerTimeout is how long the worker waits for the answer of a single provider.
+const providerTimeout = 5 * time.Second
+
+// providers returns the providers configured in the spec, or the default ones.
+// Secrets and config maps referenced from the spec are read from the given namespace.
+func providers(ctx context.Context, c client.Client, spec *v1beta1.ClusterIPSpec, namespace string) ([]ip.IPService, error) {
+	var global v1beta1.HTTPConfig
+	if spec.HTTP != nil {
+		global = *spec.HTTP
+	}
+	if len(spec.Providers) == 0 {
+		config, err := httpConfig(ctx, c, namespace, global)
+		if err != nil {
+			return nil, err
+		}
+		return ip.DefaultProviders(config), nil
+	}
+	var result []ip.IPService
+	for _, p := range spec.Providers {
+		config, err := httpConfig(ctx, c, namespace, merge(global, p.HTTPConfig))
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+		}
+		extract, err := extractor(p)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+		}
+		service := ip.NewIPService(p.Name, p.URL, extract, config, providerTimeout)
+		if p.Metadata != nil {
+			service = service.WithMetadata(ip.MetadataPaths{
+				Country: p.Metadata.CountryPath,
+				City:    p.Metadata.CityPath,
+				ASN:     p.Metadata.ASNPath,
+				Org:     p.Metadata.OrgPath,
+			})
+		}
+		key, err := p.Ed25519PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: invalid public key: %w", p.Name, err)
+		}
+		if key != nil {
+			service = service.WithPublicKey(key)
+		}
+		if p.Budget != nil {
+			service = service.WithBudget(ip.Budget{Requests: int(p.Budget.Requests), Period: p.Budget.Period.Duration})
+		}
+		result = append(result, service)
+	}
+	return result, nil
+}
+
+func extractor(p v1beta1.Provider) (ip.Extractor, error) {
+	switch p.Format {
+	case v1beta1.ResponseFormatText:
+		return ip.Text(), nil
+	case v1beta1.ResponseFormatRegex:
+		return ip.Regex(p.Regex)
+	case v1beta1.ResponseFormatEcho:
+		return ip.Echo(), nil
+	case v1beta1.ResponseFormatJSON, "":
+		if p.JSONPath == "" {
+			return ip.JSON("ip"), nil
+		}
+		return ip.JSON(p.JSONPath), nil
+	}
+	return nil, fmt.Errorf("unknown response format %s", p.Format)
+}
+
+// merge returns the global configuration overridden by the settings of a provider. Headers are merged.
+func merge(global, provider v1beta1.HTTPConfig) v1beta1.HTTPConfig {
+	result := global
+	if provider.ProxyURL != "" || provider.ProxyURLSecretRef != nil {
+		result.ProxyURL, result.ProxyURLSecretRef = provider.ProxyURL, provider.ProxyURLSecretRef
+	}
+	if provider.CABundle != nil {
+		result.CABundle = provider.CABundle
+	}
+	if provider.ClientCertificateSecretRef != nil {
+		result.ClientCertificateSecretRef = provider.ClientCertificateSecretRef
+	}
+	if len(provider.Headers) > 0 {
+		result.Headers = maps.Clone(global.Headers)
+		if result.Headers == nil {
+			result.Headers = map[string]string{}
+		}
+		maps.Copy(result.Headers, provider.Headers)
+	}
+	result.HeadersFrom = append(append([]v1beta1.HeaderSource{}, global.HeadersFrom...), provider.HeadersFrom...)
+	return result
+}
+
+func httpConfig(ctx context.Context, c client.Client, namespace string, spec v1beta1.HTTPConfig) (ip.HTTPConfig, error) {
+	var config ip.HTTPConfig
+	proxyURL := spec.ProxyURL
+	if spec.ProxyURLSecretRef != nil {
+		value, err := secretValue(ctx, c, namespace, spec.ProxyURLSecretRef.Name, spec.ProxyURLSecretRef.Key)
+		if err != nil {
+			return config, err
+		}
+		proxyURL = string(value)
+	}
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			// the URL may contain credentials, so it is not part of the error
+			return config, fmt.Errorf("invalid proxy URL")
+		}
+		config.Proxy = u
+	}
+	if ca := spec.CABundle; ca != nil {
+		var pem []byte
+		var err error
+		if ca.SecretKeyRef != nil {
+			pem, err = secretValue(ctx, c, namespace, ca.SecretKeyRef.Name, ca.SecretKeyRef.Key)
+		} else if ca.ConfigMapKeyRef != nil {
+			pem, err = configMapValue(ctx, c, namespace, ca.ConfigMapKeyRef.Name, ca.ConfigMapKeyRef.Key)
+		}
+		if err != nil {
+			return config, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return config, fmt.Errorf("CA bundle contains no PEM encoded certificate")
+		}
+		config.RootCAs = pool
+	}
+	if ref := spec.ClientCertificateSecretRef; ref != nil {
+		var secret corev1.Secret
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+			return config, err
+		}
+		cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return config, fmt.Errorf("client certificate %s: %w", ref.Name, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	config.Headers = maps.Clone(spec.Headers)
+	for _, h := range spec.HeadersFrom {
+		value, err := secretValue(ctx, c, namespace, h.SecretKeyRef.Name, h.SecretKeyRef.Key)
+		if err != nil {
+			return config, err
+		}
+		if config.Headers == nil {
+			config.Headers = map[string]string{}
+		}
+		config.Headers[h.Name] = string(value)
+	}
+	return config, nil
+}
+
+func secretValue(ctx context.Context, c client.Client, namespace, name, key string) ([]byte, error) {
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no key %s", name, key)
+	}
+	return value, nil
+}
+
+func configMapValue(ctx context.Context, c client.Client, namespace, name, key string) ([]byte, error) {
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap); err != nil {
+		return nil, err
+	}
+	if value, ok := configMap.Data[key]; ok {
+		return []byte(value), nil
+	}
+	if value, ok := configMap.BinaryData[key]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("config map %s has no key %s", name, key)
+}
@@ -0,0 +1,227 @@
+// Package worker determines the egress IP of a node label once and reports it in the status of the ClusterIP resources.
+// It uses a plain API client without caches or watches, so that worker pods start fast and use little memory.
+package worker
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kyma-project/cluster-ip/api/v1beta1"
+	"github.com/kyma-project/cluster-ip/internal/ip"
+	"github.com/kyma-project/cluster-ip/internal/status"
+)
+
+// Options select the ClusterIP and the node label the worker reports the IP for.
+type Options struct {
+	NodeLabel       string
+	NodeSpreadLabel string
+	// Name, Namespace and UID identify the ClusterIP which started the worker. The namespace is empty for a GlobalClusterIP.
+	Name      string
+	Namespace string
+	UID       string
+	// SystemNamespace holds the secrets and config maps referenced from a GlobalClusterIP.
+	SystemNamespace string
+	// StartDelay is waited before the providers are asked, so that the workers of a fleet don't start at once.
+	StartDelay time.Duration
+}
+
+// BindFlags registers the worker flags.
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.NodeLabel, "node", "", "The value of the node spread label of the node where the worker is deployed to")
+	fs.StringVar(&o.NodeSpreadLabel, "nodeSpreadLabel", "", "The node label used to spread workers")
+	fs.StringVar(&o.Name, "name", "", "The name of the ClusterIP to report the IP to")
+	fs.StringVar(&o.Namespace, "namespace", "", "The namespace of the ClusterIP to report the IP to, empty for a GlobalClusterIP")
+	fs.StringVar(&o.UID, "uid", "", "The UID of the ClusterIP to report the IP to")
+	fs.StringVar(&o.SystemNamespace, "system-namespace", "", "The operator namespace holding the secrets and config maps referenced from a GlobalClusterIP")
+	fs.DurationVar(&o.StartDelay, "start-delay", 0, "How long to wait before the providers are asked")
+}
+
+// Run determines the IP once and writes it to the node IP entry of the ClusterIP which started the worker.
+// Workers started without --name by older versions report to every ClusterIP and GlobalClusterIP
+// with the node spread label of the worker.
+func Run(ctx context.Context, c client.Client, opts Options) error {
+	if opts.NodeLabel == "" || opts.NodeSpreadLabel == "" {
+		return fmt.Errorf("the --node and --nodeSpreadLabel flags are required")
+	}
+	if opts.StartDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.StartDelay):
+		}
+	}
+	var objects []v1beta1.ClusterIPObject
+	var err error
+	if opts.Name != "" {
+		var clusterIP v1beta1.ClusterIPObject
+		clusterIP, err = get(ctx, c, opts)
+		objects = []v1beta1.ClusterIPObject{clusterIP}
+	} else {
+		objects, err = clusterIPs(ctx, c, opts.NodeSpreadLabel)
+	}
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		log.FromContext(ctx).Info("No ClusterIP uses the node spread label", "nodeSpreadLabel", opts.NodeSpreadLabel)
+		return nil
+	}
+	var errs []error
+	for _, clusterIP := range objects {
+		errs = append(errs, discoverAndReport(ctx, c, clusterIP, opts))
+	}
+	return errors.Join(errs...)
+}
+
+// discoverAndReport asks the providers configured in the ClusterIP spec and reports the result.
+// A quorum of 2 providers is required, or 1 if only one provider is configured.
+// The provider health is kept in the node IP entry, so that the circuits stay open across worker runs.
+// The requests of the other workers count against the provider budgets.
+func discoverAndReport(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, opts Options) error {
+	namespace := clusterIP.GetNamespace()
+	if namespace == "" {
+		namespace = opts.SystemNamespace
+	}
+	services, err := providers(ctx, c, clusterIP.GetSpec(), namespace)
+	if err != nil {
+		err = fmt.Errorf("invalid provider configuration: %w", err)
+		return errors.Join(err, Report(ctx, c, clusterIP, opts.NodeLabel, ip.Result{}, err))
+	}
+	tracker := ip.NewTracker(ip.DefaultFailureThreshold, ip.DefaultCoolDown)
+	for _, nodeIP := range clusterIP.GetStatus().NodeIPs {
+		if nodeIP.NodeLabel == opts.NodeLabel {
+			tracker.Load(HealthFromStatus(nodeIP.ProviderHealth))
+			continue
+		}
+		// The budgets are shared by all workers of the ClusterIP.
+		for _, h := range HealthFromStatus(nodeIP.ProviderHealth) {
+			tracker.AddUsage(h.Provider, h.WindowStart, h.Requests)
+		}
+	}
+	result, err := ip.DiscoverWith(services, min(2, len(services)), tracker, AddressPolicy(clusterIP.GetSpec()))
+	return errors.Join(err, Report(ctx, c, clusterIP, opts.NodeLabel, result, err))
+}
+
+func get(ctx context.Context, c client.Client, opts Options) (v1beta1.ClusterIPObject, error) {
+	var clusterIP v1beta1.ClusterIPObject = &v1beta1.ClusterIP{}
+	if opts.Namespace == "" {
+		clusterIP = &v1beta1.GlobalClusterIP{}
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: opts.Namespace, Name: opts.Name}, clusterIP); err != nil {
+		return nil, err
+	}
+	if opts.UID != "" && string(clusterIP.GetUID()) != opts.UID {
+		return nil, fmt.Errorf("%s was recreated, expected UID %s, got %s", opts.Name, opts.UID, clusterIP.GetUID())
+	}
+	if clusterIP.GetSpec().NodeSpreadLabel != opts.NodeSpreadLabel {
+		return nil, fmt.Errorf("%s uses node spread label %s instead of %s", opts.Name, clusterIP.GetSpec().NodeSpreadLabel, opts.NodeSpreadLabel)
+	}
+	return clusterIP, nil
+}
+
+func clusterIPs(ctx context.Context, c client.Client, nodeSpreadLabel string) ([]v1beta1.ClusterIPObject, error) {
+	var clusterIPs v1beta1.ClusterIPList
+	if err := c.List(ctx, &clusterIPs); err != nil {
+		return nil, err
+	}
+	var globalClusterIPs v1beta1.GlobalClusterIPList
+	if err := c.List(ctx, &globalClusterIPs); err != nil {
+		return nil, err
+	}
+	var objects []v1beta1.ClusterIPObject
+	for i := range clusterIPs.Items {
+		if clusterIPs.Items[i].Spec.NodeSpreadLabel == nodeSpreadLabel {
+			objects = append(objects, &clusterIPs.Items[i])
+		}
+	}
+	for i := range globalClusterIPs.Items {
+		if globalClusterIPs.Items[i].Spec.NodeSpreadLabel == nodeSpreadLabel {
+			objects = append(objects, &globalClusterIPs.Items[i])
+		}
+	}
+	return objects, nil
+}
+
+// Report writes the discovery result to the node IP entry of the node label and records an event
+// if the IP is discovered for the first time, changes or can't be determined.
+func Report(ctx context.Context, c client.Client, clusterIP v1beta1.ClusterIPObject, nodeLabel string, result ip.Result, discoverErr error) error {
+	logger := log.FromContext(ctx)
+	node := v1beta1.NodeIP{NodeLabel: nodeLabel}
+	if i := slices.IndexFunc(clusterIP.GetStatus().NodeIPs, func(n v1beta1.NodeIP) bool { return n.NodeLabel == nodeLabel }); i >= 0 {
+		node = clusterIP.GetStatus().NodeIPs[i]
+	}
+	if result.Health != nil {
+		node.ProviderHealth = HealthStatus(result.Health)
+	}
+	if discoverErr != nil {
+		previousErr := node.Error
+		node.Error = discoverErr.Error()
+		node.Evidence = Evidence(result)
+		if err := status.ApplyNodeIP(ctx, c, clusterIP, node); err != nil {
+			return err
+		}
+		if previousErr == node.Error {
+			return nil
+		}
+		return recordEvent(ctx, c, clusterIP, corev1.EventTypeWarning, "DiscoveryFailed", fmt.Sprintf("%s: %s", nodeLabel, node.Error))
+	}
+	previous := node.IPs()
+	node.Addresses = []v1beta1.Address{v1beta1.NewAddress(result.IP)}
+	node.Evidence = Evidence(result)
+	node.Metadata = Metadata(result.Metadata)
+	node.Error = ""
+	node.LastUpdateTime = metav1.Now()
+	node.ObservedGeneration = clusterIP.GetGeneration()
+	logger.Info("Status update started", "clusterIP", clusterIP.GetName(), "node", node)
+	if err := status.ApplyNodeIP(ctx, c, clusterIP, node); err != nil {
+		return err
+	}
+	if len(previous) == 0 {
+		return recordEvent(ctx, c, clusterIP, corev1.EventTypeNormal, "IPDiscovered", fmt.Sprintf("%s: %s", nodeLabel, result.IP))
+	} else if !slices.Equal(previous, node.IPs()) {
+		return recordEvent(ctx, c, clusterIP, corev1.EventTypeNormal, "IPChanged", fmt.Sprintf("%s: %s -> %s", nodeLabel, strings.Join(previous, ","), result.IP))
+	}
+	return nil
+}
+
+// AddressPolicy returns the policy accepting public addresses and the classes allowed in the spec.
+func AddressPolicy(spec *v1beta1.ClusterIPSpec) ip.Policy {
+	var policy ip.Policy
+	for _, class := range spec.AllowedAddressClasses {
+		policy.Allow = append(policy.Allow, ip.AddressClass(class))
+	}
+	return policy
+}
+
+// Metadata converts the metadata of the IP to its status representation. It returns nil if no provider returned any.
+func Metadata(m ip.Metadata) *v1beta1.IPMetadata {
+	if m.IsZero() {
+		return nil
+	}
+	return &v1beta1.IPMetadata{Country: m.Country, City: m.City, ASN: int64(m.ASN), Org: m.Org, Source: m.Source}
+}
+
+// Evidence converts the provider answers to their status representation.
+func Evidence(result ip.Result) []v1beta1.Evidence {
+	var evidence []v1beta1.Evidence
+	for _, e := range result.Evidence {
+		item := v1beta1.Evidence{Provider: e.Provider, IP: e.IP, StatusCode: int32(e.StatusCode())}
+		if e.Err != nil {
+			item.Error = e.Err.Error()
+			item.Reason = string(e.Reason())
+		}
+		evidence = append(evidence, item)
+	}
+	return evidence
+}